@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/shardqa/doc-mcp/internal/server"
+)
+
+const usage = `usage: doc-mcp [command] [flags]
+
+Without a command doc-mcp serves MCP over stdio, whatever other arguments
+it is given. Commands:
+  link-report   list orphan docs, dead ends and disconnected components
+  export-graph  print the link graph as JSON, Graphviz DOT or Mermaid
+`
+
+// isCommand reports whether name is a command-line subcommand. Any other
+// argument, such as one an MCP client passes along, leaves doc-mcp serving.
+func isCommand(name string) bool {
+	return name == "link-report" || name == "export-graph"
+}
+
+// runCommand runs a command-line subcommand instead of the MCP server and
+// returns the process exit code.
+func runCommand(args []string, stdout, stderr io.Writer) int {
+	switch args[0] {
+	case "link-report":
+		return linkReportCommand(args[1:], stdout, stderr)
+	case "export-graph":
+		return exportGraphCommand(args[1:], stdout, stderr)
+	default:
+		fmt.Fprintf(stderr, "unknown command %q\n\n%s", args[0], usage)
+		return 2
+	}
+}
+
+func linkReportCommand(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("link-report", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	root := flags.String("root", "doc", "documentation folder")
+	asJSON := flags.Bool("json", false, "print the report as JSON")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	report, err := server.LinkReportLogic(*root)
+	if err != nil {
+		fmt.Fprintf(stderr, "link-report: %v\n", err)
+		return 1
+	}
+	if *asJSON {
+		data, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Fprintln(stdout, string(data))
+		return 0
+	}
+
+	fmt.Fprintf(stdout, "%d documents\n", report.Documents)
+	printEntries(stdout, "Orphans (no inbound links)", "suggested links from", report.Orphans)
+	printEntries(stdout, "Dead ends (no outbound links)", "suggested links to", report.DeadEnds)
+	if len(report.Components) > 0 {
+		fmt.Fprintf(stdout, "\nDisconnected components (%d)\n", len(report.Components))
+		for i, component := range report.Components {
+			fmt.Fprintf(stdout, "  %d. %s\n", i+1, strings.Join(component, ", "))
+		}
+	}
+	return 0
+}
+
+func exportGraphCommand(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("export-graph", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	root := flags.String("root", "doc", "documentation folder")
+	opts := server.ExportOptions{}
+	flags.StringVar(&opts.Format, "format", server.GraphFormatJSON, "json, dot or mermaid")
+	flags.BoolVar(&opts.Cluster, "cluster", false, "group documents by folder")
+	flags.StringVar(&opts.Path, "path", "", "only export documents under this folder")
+	flags.StringVar(&opts.Tag, "tag", "", "only export documents with this tag")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	graph, err := server.ExportGraphLogic(*root, opts)
+	if err != nil {
+		fmt.Fprintf(stderr, "export-graph: %v\n", err)
+		return 1
+	}
+	fmt.Fprint(stdout, graph)
+	if !strings.HasSuffix(graph, "\n") {
+		fmt.Fprintln(stdout)
+	}
+	return 0
+}
+
+func printEntries(w io.Writer, heading, label string, entries []server.ReportEntry) {
+	fmt.Fprintf(w, "\n%s (%d)\n", heading, len(entries))
+	for _, entry := range entries {
+		fmt.Fprintf(w, "  %s (%s)\n", entry.Path, entry.Title)
+		if len(entry.Suggestions) == 0 {
+			continue
+		}
+		paths := []string{}
+		for _, s := range entry.Suggestions {
+			paths = append(paths, s.Path)
+		}
+		fmt.Fprintf(w, "    %s: %s\n", label, strings.Join(paths, ", "))
+	}
+}
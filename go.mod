@@ -2,15 +2,17 @@ module github.com/shardqa/doc-mcp
 
 go 1.24.2
 
+require (
+	github.com/modelcontextprotocol/go-sdk v0.1.0
+	github.com/stretchr/testify v1.10.0
+	github.com/yuin/goldmark v1.5.4
+	go.abhg.dev/goldmark/hashtag v0.3.1
+	gopkg.in/yaml.v3 v3.0.1
+)
+
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/forPelevin/gomoji v1.1.3 // indirect
-	github.com/modelcontextprotocol/go-sdk v0.1.0 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/rivo/uniseg v0.2.0 // indirect
-	github.com/stretchr/testify v1.10.0 // indirect
-	github.com/will-wow/larkdown v0.0.8 // indirect
-	github.com/yuin/goldmark v1.5.4 // indirect
-	go.abhg.dev/goldmark/hashtag v0.3.1 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
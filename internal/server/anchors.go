@@ -0,0 +1,376 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+// splitLinkDest splits a link destination into its path and the ?query
+// and #fragment that follow it, which are returned together. Escaped
+// characters such as \# and the # of references such as &#95; belong to
+// the path.
+func splitLinkDest(dest string) (string, string) {
+	for i := 0; i < len(dest); i++ {
+		switch dest[i] {
+		case '\\':
+			i++
+		case '?':
+			return dest[:i], dest[i:]
+		case '#':
+			if i == 0 || dest[i-1] != '&' {
+				return dest[:i], dest[i:]
+			}
+		}
+	}
+	return dest, ""
+}
+
+// linkFragment returns the fragment of a destination suffix without its #.
+func linkFragment(suffix string) string {
+	if i := strings.IndexByte(suffix, '#'); i >= 0 {
+		return suffix[i+1:]
+	}
+	return ""
+}
+
+// isLocalMarkdownDest reports whether dest points at a markdown file in the
+// doc tree, possibly with a query or fragment.
+func isLocalMarkdownDest(dest string) bool {
+	path, _ := splitLinkDest(dest)
+	return strings.HasSuffix(path, ".md") && !strings.HasPrefix(dest, "http") && !strings.Contains(path, "://")
+}
+
+// documentAnchor is a heading of a document with its GitHub-style anchor.
+type documentAnchor struct {
+	heading *ast.Heading
+	text    string
+	anchor  string
+}
+
+// documentAnchors lists the headings of a parsed document in order. Like
+// GitHub, repeated slugs get -1, -2 and so on appended.
+func documentAnchors(doc ast.Node, source []byte) []documentAnchor {
+	anchors := []documentAnchor{}
+	seen := make(map[string]int)
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		heading, ok := n.(*ast.Heading)
+		if !ok || !entering {
+			return ast.WalkContinue, nil
+		}
+		title := plainText(heading, source)
+		slug := headingSlug(title)
+		anchor := slug
+		if count := seen[slug]; count > 0 {
+			anchor = fmt.Sprintf("%s-%d", slug, count)
+		}
+		seen[slug]++
+		anchors = append(anchors, documentAnchor{heading: heading, text: title, anchor: anchor})
+		return ast.WalkSkipChildren, nil
+	})
+	return anchors
+}
+
+func anchorSet(source []byte) map[string]bool {
+	doc := goldmark.New().Parser().Parse(text.NewReader(source))
+	set := make(map[string]bool)
+	for _, a := range documentAnchors(doc, source) {
+		set[a.anchor] = true
+	}
+	return set
+}
+
+// validateOwnAnchors checks the links in content to its own headings, such
+// as [see below](#usage).
+func validateOwnAnchors(content string) []string {
+	return checkAnchors(content, "")
+}
+
+// validateLinkedAnchors checks the #fragments of the links in content to
+// other documents against their headings. dir is the folder the content
+// lives in.
+func validateLinkedAnchors(content, dir string) []string {
+	return checkAnchors(content, dir)
+}
+
+// checkAnchors checks links to the document's own headings when dir is
+// empty, and links to the headings of other documents otherwise.
+func checkAnchors(content, dir string) []string {
+	source := []byte(content)
+	doc := goldmark.New().Parser().Parse(text.NewReader(source))
+	own := anchorSet(source)
+	others := make(map[string]map[string]bool)
+
+	warnings := []string{}
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		link, ok := n.(*ast.Link)
+		if !ok || !entering {
+			return ast.WalkContinue, nil
+		}
+		dest := string(link.Destination)
+		path, suffix := splitLinkDest(dest)
+		fragment := linkFragment(suffix)
+		if fragment == "" {
+			return ast.WalkContinue, nil
+		}
+
+		switch {
+		case path == "" && dir == "":
+			if !own[fragment] {
+				warnings = append(warnings, fmt.Sprintf("Link %s does not match any heading in this file.", dest))
+			}
+		case dir != "" && isLocalMarkdownDest(dest):
+			file := filepath.Join(dir, unescapedPath(path))
+			anchors, ok := others[file]
+			if !ok {
+				target, err := os.ReadFile(file)
+				if err != nil {
+					return ast.WalkContinue, nil
+				}
+				anchors = anchorSet(target)
+				others[file] = anchors
+			}
+			if !anchors[fragment] {
+				warnings = append(warnings, fmt.Sprintf("Link %s does not match any heading in %s.", dest, path))
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	return warnings
+}
+
+// SectionEditResult reports the outcome of a section edit. Updated lists the
+// files whose links were rewritten to follow a renamed heading.
+type SectionEditResult struct {
+	Path      string   `json:"path"`
+	Anchor    string   `json:"anchor"`
+	OldAnchor string   `json:"old_anchor,omitempty"`
+	Updated   []string `json:"updated"`
+}
+
+// EditSectionLogic edits the section of a document under root that starts
+// at heading, matched by its text or its anchor. newHeading renames the
+// heading; content, when not empty, replaces the body of the section up to
+// the next heading of the same or a higher level. Links across root to every
+// anchor the edit changes are rewritten, including those of repeated
+// headings whose -1, -2 suffix shifted.
+func EditSectionLogic(root, path, heading, newHeading, content string) (*SectionEditResult, error) {
+	file, err := resolveInRoot(root, path)
+	if err != nil {
+		return nil, err
+	}
+	source, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %w", path, err)
+	}
+	if newHeading == "" && content == "" {
+		return nil, fmt.Errorf("nothing to change: pass new_heading, content or both")
+	}
+
+	doc := goldmark.New().Parser().Parse(text.NewReader(source))
+	anchors := documentAnchors(doc, source)
+	index := -1
+	wanted := strings.TrimPrefix(strings.TrimSpace(heading), "#")
+	for i, a := range anchors {
+		if strings.EqualFold(a.text, strings.TrimSpace(heading)) || a.anchor == wanted || a.anchor == headingSlug(heading) {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return nil, fmt.Errorf("no heading %q in %s", heading, path)
+	}
+	target := anchors[index]
+
+	headingStart, bodyStart, ok := headingBounds(target.heading, source)
+	if !ok {
+		return nil, fmt.Errorf("heading %q in %s is empty", heading, path)
+	}
+	sectionEnd := len(source)
+	next := len(anchors)
+	for i, a := range anchors[index+1:] {
+		if start, _, ok := headingBounds(a.heading, source); ok && a.heading.Level <= target.heading.Level {
+			sectionEnd = start
+			next = index + 1 + i
+			break
+		}
+	}
+
+	edits := []sourceEdit{}
+	if newHeading != "" {
+		line := strings.Repeat("#", target.heading.Level) + " " + strings.TrimSpace(newHeading) + "\n"
+		edits = append(edits, sourceEdit{start: headingStart, stop: bodyStart, text: line})
+	}
+	if content != "" {
+		body := "\n" + strings.Trim(content, "\n") + "\n"
+		if sectionEnd < len(source) {
+			body += "\n"
+		}
+		edits = append(edits, sourceEdit{start: bodyStart, stop: sectionEnd, text: body})
+	}
+	updated := applyEdits(source, edits)
+	if err := os.WriteFile(file, updated, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	newAnchors := documentAnchors(goldmark.New().Parser().Parse(text.NewReader(updated)), updated)
+	result := &SectionEditResult{Path: path, Anchor: newAnchors[index].anchor, Updated: []string{}}
+	if result.Anchor != target.anchor {
+		result.OldAnchor = target.anchor
+	}
+
+	// Headings before the section keep their position and so do those after
+	// it, counted from the end. Subheadings replaced by content have no
+	// counterpart.
+	renames := make(map[string]anchorRename)
+	for i, old := range anchors {
+		j := i
+		if content != "" && i > index {
+			if i < next {
+				continue
+			}
+			j = i - len(anchors) + len(newAnchors)
+		}
+		if j < 0 || j >= len(newAnchors) {
+			continue
+		}
+		if renamed := newAnchors[j]; renamed.anchor != old.anchor {
+			heading := renamed.text
+			if i == index && newHeading != "" {
+				heading = strings.TrimSpace(newHeading)
+			}
+			renames[old.anchor] = anchorRename{anchor: renamed.anchor, heading: heading}
+		}
+	}
+	if len(renames) > 0 {
+		result.Updated, err = rewriteAnchorLinks(root, file, renames)
+		if err != nil {
+			return result, fmt.Errorf("failed to update links to %s: %w", path, err)
+		}
+	}
+	return result, nil
+}
+
+// anchorRename is the new anchor of a heading, and its text for wiki links.
+type anchorRename struct {
+	anchor  string
+	heading string
+}
+
+// headingBounds returns the offsets where a heading's lines start and where
+// the content after it starts. It fails for empty headings such as a lone
+// "#", which keep no position in the source.
+func headingBounds(heading *ast.Heading, source []byte) (int, int, bool) {
+	lines := heading.Lines()
+	if lines.Len() == 0 {
+		return 0, 0, false
+	}
+	start := bytes.LastIndexByte(source[:lines.At(0).Start], '\n') + 1
+	end := lineEnd(source, lines.At(lines.Len()-1).Stop)
+	// A setext heading is followed by its underline.
+	if next := lineEnd(source, end); end < len(source) && isSetextUnderline(string(source[end:next])) {
+		end = next
+	}
+	return start, end, true
+}
+
+// lineEnd returns the offset just past the line containing offset.
+func lineEnd(source []byte, offset int) int {
+	if offset > 0 && offset <= len(source) && source[offset-1] == '\n' {
+		return offset
+	}
+	if i := bytes.IndexByte(source[offset:], '\n'); i >= 0 {
+		return offset + i + 1
+	}
+	return len(source)
+}
+
+// rewriteAnchorLinks points the links to the anchors of file that renames
+// maps, from any document under root including file itself, at their new
+// anchors. Wiki links get the new heading text instead. It returns the files
+// it rewrote.
+func rewriteAnchorLinks(root, file string, renames map[string]anchorRename) ([]string, error) {
+	absFile, err := filepath.Abs(file)
+	if err != nil {
+		return nil, err
+	}
+	files, err := markdownFilesIn(root)
+	if err != nil {
+		return nil, err
+	}
+	resolver, err := wikiResolverFor(root, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	mdParser := goldmark.New()
+
+	updated := []string{}
+	for _, path := range files {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return updated, err
+		}
+		source, err := os.ReadFile(abs)
+		if err != nil {
+			return updated, fmt.Errorf("failed to read file %s: %w", path, err)
+		}
+
+		_, links := parseLinks(mdParser, source)
+		linkEdits := links.retarget(func(dest string) (string, bool) {
+			linkPath, suffix := splitLinkDest(dest)
+			oldAnchor := linkFragment(suffix)
+			rename, ok := renames[oldAnchor]
+			if oldAnchor == "" || !ok {
+				return "", false
+			}
+			if linkPath == "" {
+				if abs != absFile {
+					return "", false
+				}
+			} else if !isLocalMarkdownDest(dest) {
+				return "", false
+			} else if target, err := filepath.Abs(filepath.Join(filepath.Dir(abs), unescapedPath(linkPath))); err != nil || target != absFile {
+				return "", false
+			}
+			query := strings.TrimSuffix(suffix, "#"+oldAnchor)
+			return linkPath + query + "#" + rename.anchor, true
+		})
+		changed := len(linkEdits) > 0
+		output := applyEdits(source, linkEdits)
+
+		edits := []sourceEdit{}
+		for _, link := range wikiLinksIn(newMarkdownParser().Parser().Parse(text.NewReader(output))) {
+			page, heading := splitWikiTarget(string(link.Target))
+			rename, ok := renames[headingSlug(heading)]
+			if heading == "" || !ok {
+				continue
+			}
+			// [[#Heading]] points into the document itself.
+			if page == "" && abs != absFile || page != "" && resolver.resolve(page) != absFile {
+				continue
+			}
+			replacement := "[[" + page + "#" + rename.heading
+			if link.Alias != nil {
+				replacement += "|" + string(link.Alias)
+			}
+			edits = append(edits, sourceEdit{start: link.Segment.Start, stop: link.Segment.Stop, text: replacement + "]]"})
+		}
+		if !changed && len(edits) == 0 {
+			continue
+		}
+		output = applyEdits(output, edits)
+
+		if err := os.WriteFile(abs, output, 0644); err != nil {
+			return updated, fmt.Errorf("failed to write updated markdown to %s: %w", path, err)
+		}
+		updated = append(updated, path)
+	}
+	return updated, nil
+}
@@ -0,0 +1,328 @@
+package server
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// assetsFolderName is the folder next to a document where its images and
+// attachments are kept. Like index.md it stays with its folder.
+const assetsFolderName = "assets"
+
+// maxAssetSize is the largest asset StoreAssetLogic accepts, in bytes.
+const maxAssetSize = 10 << 20
+
+var urlSchemeRe = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*:`)
+
+var imageExtensions = map[string]bool{
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true,
+	".svg": true, ".webp": true, ".bmp": true, ".avif": true,
+}
+
+// StoreAssetOptions controls where StoreAssetLogic puts an asset. NextToDoc
+// stores it in the document's own folder instead of its assets folder, and
+// Overwrite replaces an existing file of the same name.
+type StoreAssetOptions struct {
+	NextToDoc bool
+	Overwrite bool
+}
+
+// StoredAsset describes a stored asset. Markdown is an image or link that
+// can be pasted into the document as is.
+type StoredAsset struct {
+	Path     string `json:"path"`
+	Size     int    `json:"size"`
+	Markdown string `json:"markdown"`
+}
+
+// MissingAsset is a reference to a local file that does not exist.
+type MissingAsset struct {
+	Document    string `json:"document"`
+	Line        int    `json:"line"`
+	Destination string `json:"destination"`
+}
+
+// AssetReport lists the assets under a root that no document references
+// and the references to assets that do not exist.
+type AssetReport struct {
+	Assets  int            `json:"assets"`
+	Unused  []string       `json:"unused"`
+	Missing []MissingAsset `json:"missing"`
+}
+
+// assetRef is an image or a link to a local file other than a document.
+type assetRef struct {
+	node ast.Node
+	dest string
+	path string
+}
+
+// isLocalDest reports whether dest points at a file in the doc tree rather
+// than at a URL, an absolute path or a heading of the same document.
+func isLocalDest(dest string) bool {
+	path, _ := splitLinkDest(dest)
+	return path != "" && !strings.HasPrefix(path, "/") && !urlSchemeRe.MatchString(dest)
+}
+
+// unescapedPath decodes a link path as written in markdown into the file
+// name it stands for: backslash escapes such as my\_file.md, entity and
+// numeric character references, and percent-encoding such as
+// my%20diagram.png. Invalid percent-encoding is kept as written.
+func unescapedPath(path string) string {
+	b := util.UnescapePunctuations([]byte(path))
+	b = util.ResolveNumericReferences(b)
+	path = string(util.ResolveEntityNames(b))
+	if unescaped, err := url.PathUnescape(path); err == nil {
+		return unescaped
+	}
+	return path
+}
+
+// assetRefs lists the images of a parsed document and its links to local
+// files that are not markdown documents.
+func assetRefs(doc ast.Node) []assetRef {
+	refs := []assetRef{}
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		var dest string
+		switch node := n.(type) {
+		case *ast.Image:
+			dest = string(node.Destination)
+		case *ast.Link:
+			dest = string(node.Destination)
+		default:
+			return ast.WalkContinue, nil
+		}
+		if !isLocalDest(dest) || isLocalMarkdownDest(dest) {
+			return ast.WalkContinue, nil
+		}
+		path, _ := splitLinkDest(dest)
+		refs = append(refs, assetRef{node: n, dest: dest, path: unescapedPath(path)})
+		return ast.WalkContinue, nil
+	})
+	return refs
+}
+
+// StoreAssetLogic decodes a base64 payload and stores it as name in the
+// assets folder of the document at docPath, both relative to root. The
+// document does not have to exist yet. data may be a data URL. Assets larger
+// than 10 MiB are refused.
+func StoreAssetLogic(root, docPath, name, data string, opts StoreAssetOptions) (*StoredAsset, error) {
+	docFile, err := resolveInRoot(root, docPath)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(docPath, ".md") {
+		return nil, fmt.Errorf("doc %s is not a markdown file", docPath)
+	}
+	if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return nil, fmt.Errorf("invalid asset name %q: must be a plain file name", name)
+	}
+	if strings.HasSuffix(name, ".md") {
+		return nil, fmt.Errorf("asset %s is a markdown file; create it as a document instead", name)
+	}
+
+	if i := strings.Index(data, "base64,"); i >= 0 && strings.HasPrefix(data, "data:") {
+		data = data[i+len("base64,"):]
+	}
+	encoded := strings.Join(strings.Fields(data), "")
+	if base64.StdEncoding.DecodedLen(len(encoded)) > maxAssetSize+2 {
+		return nil, fmt.Errorf("asset %s is larger than %d bytes", name, maxAssetSize)
+	}
+	content, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode asset %s: %w", name, err)
+	}
+	if len(content) > maxAssetSize {
+		return nil, fmt.Errorf("asset %s is larger than %d bytes", name, maxAssetSize)
+	}
+
+	rel := name
+	if !opts.NextToDoc {
+		rel = assetsFolderName + "/" + name
+	}
+	file := filepath.Join(filepath.Dir(docFile), filepath.FromSlash(rel))
+	if _, err := os.Stat(file); err == nil && !opts.Overwrite {
+		return nil, fmt.Errorf("asset %s already exists", rootRelPath(root, file))
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory %s: %w", filepath.Dir(file), err)
+	}
+	if err := os.WriteFile(file, content, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write asset %s: %w", name, err)
+	}
+
+	label := escapeLinkText(strings.TrimSuffix(name, filepath.Ext(name)))
+	markdown := fmt.Sprintf("[%s](%s)", label, escapeLinkDestination(rel))
+	if imageExtensions[strings.ToLower(filepath.Ext(name))] {
+		markdown = "!" + markdown
+	}
+
+	return &StoredAsset{
+		Path:     rootRelPath(root, file),
+		Size:     len(content),
+		Markdown: markdown,
+	}, nil
+}
+
+// AssetReportLogic reports the files under root other than markdown
+// documents that no document references, and the images and links to local
+// files that do not exist. Hidden folders are skipped.
+func AssetReportLogic(root string) (*AssetReport, error) {
+	assets := make(map[string]bool)
+	err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(d.Name(), ".") && path != root {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.IsDir() && !strings.HasSuffix(d.Name(), ".md") {
+			abs, err := filepath.Abs(path)
+			if err != nil {
+				return err
+			}
+			assets[abs] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list assets in %s: %w", root, err)
+	}
+
+	files, err := markdownFilesIn(root)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &AssetReport{Assets: len(assets), Unused: []string{}, Missing: []MissingAsset{}}
+	used := make(map[string]bool)
+	mdParser := goldmark.New()
+	for _, file := range files {
+		source, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file %s: %w", file, err)
+		}
+		dir, err := filepath.Abs(filepath.Dir(file))
+		if err != nil {
+			return nil, err
+		}
+		for _, ref := range assetRefs(mdParser.Parser().Parse(text.NewReader(source))) {
+			target := filepath.Join(dir, filepath.FromSlash(ref.path))
+			if _, err := os.Stat(target); err != nil {
+				report.Missing = append(report.Missing, MissingAsset{
+					Document:    rootRelPath(root, file),
+					Line:        nodeLine(ref.node, source),
+					Destination: ref.dest,
+				})
+				continue
+			}
+			used[target] = true
+		}
+	}
+
+	for asset := range assets {
+		if !used[asset] {
+			report.Unused = append(report.Unused, rootRelPath(root, asset))
+		}
+	}
+	sort.Strings(report.Unused)
+	return report, nil
+}
+
+// carryAssets moves the assets referenced by the documents in movedFiles
+// along with them, keeping the same place relative to the document, and
+// records the moves in movedFiles so their references get rewritten. Assets
+// outside the document's folder, or also referenced by documents that did
+// not move with it, stay where they are.
+func carryAssets(root string, movedFiles map[string]string) error {
+	originalPaths := make(map[string]string, len(movedFiles))
+	for oldPath, newPath := range movedFiles {
+		originalPaths[newPath] = oldPath
+	}
+
+	files, err := markdownFilesIn(root)
+	if err != nil {
+		return err
+	}
+
+	// carried maps the old path of an asset to where it should go, or to ""
+	// when it has to stay.
+	carried := make(map[string]string)
+	mdParser := goldmark.New()
+	for _, file := range files {
+		newPath, err := filepath.Abs(file)
+		if err != nil {
+			return err
+		}
+		oldPath, moved := originalPaths[newPath]
+		if !moved {
+			oldPath = newPath
+		}
+
+		source, err := os.ReadFile(newPath)
+		if err != nil {
+			return fmt.Errorf("failed to read file %s: %w", newPath, err)
+		}
+		for _, ref := range assetRefs(mdParser.Parser().Parse(text.NewReader(source))) {
+			asset := filepath.Join(filepath.Dir(oldPath), filepath.FromSlash(ref.path))
+			if _, done := movedFiles[asset]; done {
+				continue
+			}
+			dest := ""
+			if rel, err := filepath.Rel(filepath.Dir(oldPath), asset); moved && err == nil && !strings.HasPrefix(rel, "..") {
+				dest = filepath.Join(filepath.Dir(newPath), rel)
+			}
+			if previous, seen := carried[asset]; seen && previous != dest {
+				dest = ""
+			}
+			carried[asset] = dest
+		}
+	}
+
+	assets := make([]string, 0, len(carried))
+	for asset, dest := range carried {
+		if dest != "" {
+			assets = append(assets, asset)
+		}
+	}
+	sort.Strings(assets)
+
+	for _, asset := range assets {
+		dest := carried[asset]
+		if info, err := os.Stat(asset); err != nil || info.IsDir() {
+			continue
+		}
+		if _, err := os.Stat(dest); err == nil {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return fmt.Errorf("failed to create directory %s: %w", filepath.Dir(dest), err)
+		}
+		if err := os.Rename(asset, dest); err != nil {
+			return fmt.Errorf("failed to move asset from %s to %s: %w", asset, dest, err)
+		}
+		recordMove(movedFiles, asset, dest)
+		if filepath.Base(filepath.Dir(asset)) == assetsFolderName {
+			os.Remove(filepath.Dir(asset))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"os"
+	"strconv"
+)
+
+const (
+	AutoRefactorOff     = "off"
+	AutoRefactorSuggest = "suggest"
+	AutoRefactorApply   = "apply"
+)
+
+// Config holds the server-wide settings. Everything is opt-in and read from
+// DOC_MCP_* environment variables so it can be set in the client's mcp.json.
+type Config struct {
+	// AutoRefactor is what happens when a create or move leaves a folder
+	// with more than 10 items: AutoRefactorOff (default) does nothing,
+	// AutoRefactorSuggest returns the proposed plan and AutoRefactorApply
+	// refactors the folder right away.
+	AutoRefactor string
+	// Refactor configures the automatic refactor.
+	Refactor RefactorOptions
+	// Git configures automatic commits of every mutating tool call.
+	Git GitOptions
+	// Embedding selects the embedder used by semantic search.
+	Embedding EmbeddingOptions
+	// TOC configures automatic tables of contents.
+	TOC TOCOptions
+}
+
+var config = Config{AutoRefactor: AutoRefactorOff}
+
+// SetConfig replaces the server-wide settings.
+func SetConfig(c Config) {
+	config = c
+}
+
+// ConfigFromEnv reads the settings from the environment.
+func ConfigFromEnv() Config {
+	c := Config{
+		AutoRefactor: os.Getenv("DOC_MCP_AUTO_REFACTOR"),
+		Refactor: RefactorOptions{
+			Strategy: os.Getenv("DOC_MCP_REFACTOR_STRATEGY"),
+			GroupKey: os.Getenv("DOC_MCP_REFACTOR_GROUP_KEY"),
+		},
+	}
+	if c.AutoRefactor == "" {
+		c.AutoRefactor = AutoRefactorOff
+	}
+	if clusters, err := strconv.Atoi(os.Getenv("DOC_MCP_REFACTOR_CLUSTERS")); err == nil {
+		c.Refactor.Clusters = clusters
+	}
+	c.Refactor.Indexes = os.Getenv("DOC_MCP_REFACTOR_INDEXES") == "true"
+	c.Git = GitOptions{
+		AutoCommit:  os.Getenv("DOC_MCP_GIT_AUTOCOMMIT") == "true",
+		Branch:      os.Getenv("DOC_MCP_GIT_BRANCH"),
+		AuthorName:  os.Getenv("DOC_MCP_GIT_AUTHOR_NAME"),
+		AuthorEmail: os.Getenv("DOC_MCP_GIT_AUTHOR_EMAIL"),
+	}
+	c.Embedding = EmbeddingOptions{
+		URL:    os.Getenv("DOC_MCP_EMBEDDING_URL"),
+		Model:  os.Getenv("DOC_MCP_EMBEDDING_MODEL"),
+		APIKey: os.Getenv("DOC_MCP_EMBEDDING_API_KEY"),
+	}
+	c.TOC.Auto = os.Getenv("DOC_MCP_TOC_AUTO") == "true"
+	if depth, err := strconv.Atoi(os.Getenv("DOC_MCP_TOC_DEPTH")); err == nil {
+		c.TOC.Depth = depth
+	}
+	return c
+}
@@ -0,0 +1,269 @@
+package server
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+// stateDir is the hidden folder under the doc root where the server keeps
+// its own data.
+const stateDir = ".doc-mcp"
+
+// DeleteOptions controls what DeleteMarkdownFileLogic does about documents
+// that still link to the target. Without any of them set, deletion is refused
+// while referrers exist.
+type DeleteOptions struct {
+	// Force deletes even though the links will break.
+	Force bool
+	// Replacement is a path relative to the root that inbound links are
+	// rewritten to point at.
+	Replacement string
+	// StripLinks replaces inbound links with their plain text.
+	StripLinks bool
+}
+
+// DeleteResult reports the outcome of a deletion.
+type DeleteResult struct {
+	Trashed   string   `json:"trashed,omitempty"`
+	Referrers []string `json:"referrers"`
+	Updated   []string `json:"updated"`
+}
+
+// trashDir returns the folder deleted files of root are moved to.
+func trashDir(root string) string {
+	return filepath.Join(root, stateDir, "trash")
+}
+
+// DeleteMarkdownFileLogic moves a file or folder under root into the trash
+// folder root/.doc-mcp/trash/<timestamp>/, from where it can be restored by
+// hand. Documents linking to it are listed as referrers and, depending on
+// opts, rewritten first.
+func DeleteMarkdownFileLogic(root, path string, opts DeleteOptions) (*DeleteResult, error) {
+	target, err := resolveInRoot(root, path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(target); err != nil {
+		return nil, fmt.Errorf("%s not found: %w", path, err)
+	}
+
+	targets, err := targetFiles(target)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &DeleteResult{Updated: []string{}}
+	result.Referrers, err = findReferrers(root, targets)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(result.Referrers) > 0 {
+		switch {
+		case opts.Replacement != "":
+			replacement, err := resolveInRoot(root, opts.Replacement)
+			if err != nil {
+				return result, err
+			}
+			if _, err := os.Stat(replacement); err != nil {
+				return result, fmt.Errorf("replacement %s not found: %w", opts.Replacement, err)
+			}
+			absReplacement, _ := filepath.Abs(replacement)
+			absTarget, _ := filepath.Abs(target)
+			if absReplacement == absTarget || strings.HasPrefix(absReplacement, absTarget+string(filepath.Separator)) {
+				return result, fmt.Errorf("replacement %s is being deleted with %s", opts.Replacement, path)
+			}
+
+			redirects := make(map[string]string)
+			for t := range targets {
+				redirects[t] = absReplacement
+			}
+			result.Updated, err = rewriteLinks(root, nil, redirects)
+			if err != nil {
+				return result, fmt.Errorf("failed to rewrite links: %w", err)
+			}
+		case opts.StripLinks:
+			result.Updated, err = stripLinks(root, targets)
+			if err != nil {
+				return result, fmt.Errorf("failed to strip links: %w", err)
+			}
+		case !opts.Force:
+			return result, fmt.Errorf("%s is linked from %d documents: %s; pass force, replacement or strip_links to delete it", path, len(result.Referrers), strings.Join(result.Referrers, ", "))
+		}
+	}
+
+	rel, _ := filepath.Rel(root, target)
+	trashed := filepath.Join(trashDir(root), time.Now().UTC().Format("20060102T150405.000000000Z"), rel)
+	if err := os.MkdirAll(filepath.Dir(trashed), 0755); err != nil {
+		return result, fmt.Errorf("failed to create trash folder: %w", err)
+	}
+	if err := os.Rename(target, trashed); err != nil {
+		return result, fmt.Errorf("failed to move %s to trash: %w", path, err)
+	}
+	result.Trashed = trashed
+
+	return result, nil
+}
+
+// targetFiles returns the absolute paths of the markdown files at path,
+// which may be a single file or a folder.
+func targetFiles(path string) (map[string]bool, error) {
+	targets := make(map[string]bool)
+	err := filepath.WalkDir(path, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && strings.HasSuffix(p, ".md") {
+			abs, err := filepath.Abs(p)
+			if err != nil {
+				return err
+			}
+			targets[abs] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files in %s: %w", path, err)
+	}
+	return targets, nil
+}
+
+// relativeMarkdownLinks returns the links of a parsed document that point at
+// local markdown files, with their destinations resolved against dir and
+// stripped of any query or fragment.
+func relativeMarkdownLinks(doc ast.Node, dir string) map[*ast.Link]string {
+	links := make(map[*ast.Link]string)
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		link, ok := n.(*ast.Link)
+		if !ok || !entering {
+			return ast.WalkContinue, nil
+		}
+		dest := string(link.Destination)
+		if !isLocalMarkdownDest(dest) {
+			return ast.WalkContinue, nil
+		}
+		path, _ := splitLinkDest(dest)
+		if abs, err := filepath.Abs(filepath.Join(dir, unescapedPath(path))); err == nil {
+			links[link] = abs
+		}
+		return ast.WalkContinue, nil
+	})
+	return links
+}
+
+// findReferrers lists the markdown files under root, other than the targets
+// themselves, that link to any of the targets.
+func findReferrers(root string, targets map[string]bool) ([]string, error) {
+	files, err := markdownFilesIn(root)
+	if err != nil {
+		return nil, err
+	}
+
+	resolver, err := wikiResolverFor(root, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	mdParser := newMarkdownParser()
+	referrers := []string{}
+	for _, file := range files {
+		abs, _ := filepath.Abs(file)
+		if targets[abs] {
+			continue
+		}
+
+		source, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file %s: %w", file, err)
+		}
+
+		doc := mdParser.Parser().Parse(text.NewReader(source))
+		refers := false
+		for _, dest := range relativeMarkdownLinks(doc, filepath.Dir(abs)) {
+			refers = refers || targets[dest]
+		}
+		for _, link := range wikiLinksIn(doc) {
+			refers = refers || targets[resolver.resolve(string(link.Target))]
+		}
+		if refers {
+			referrers = append(referrers, file)
+		}
+	}
+
+	sort.Strings(referrers)
+	return referrers, nil
+}
+
+// stripLinks replaces every link to one of the targets with its text in the
+// markdown files under root. It returns the files that were rewritten.
+func stripLinks(root string, targets map[string]bool) ([]string, error) {
+	files, err := markdownFilesIn(root)
+	if err != nil {
+		return nil, err
+	}
+
+	resolver, err := wikiResolverFor(root, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	mdParser := goldmark.New()
+
+	updated := []string{}
+	for _, file := range files {
+		abs, _ := filepath.Abs(file)
+		if targets[abs] {
+			continue
+		}
+
+		source, err := os.ReadFile(file)
+		if err != nil {
+			return updated, fmt.Errorf("failed to read file %s: %w", file, err)
+		}
+
+		doc, links := parseLinks(mdParser, source)
+		linkEdits := []sourceEdit{}
+		for link, dest := range relativeMarkdownLinks(doc, filepath.Dir(abs)) {
+			span, ok := links.spans[link]
+			if !targets[dest] || !ok {
+				continue
+			}
+			linkEdits = append(linkEdits, sourceEdit{start: span.start, stop: span.stop, text: string(source[span.textStart:span.textStop])})
+		}
+		// Reference definitions of the targets are left without links.
+		for _, def := range links.definitions {
+			path, _ := splitLinkDest(def.dest)
+			if dest, err := filepath.Abs(filepath.Join(filepath.Dir(abs), unescapedPath(path))); err == nil && isLocalMarkdownDest(def.dest) && targets[dest] {
+				linkEdits = append(linkEdits, sourceEdit{start: def.start, stop: def.stop})
+			}
+		}
+		changed := len(linkEdits) > 0
+		output := applyEdits(source, linkEdits)
+
+		edits := []sourceEdit{}
+		for _, link := range wikiLinksIn(newMarkdownParser().Parser().Parse(text.NewReader(output))) {
+			if targets[resolver.resolve(string(link.Target))] {
+				edits = append(edits, sourceEdit{start: link.Segment.Start, stop: link.Segment.Stop, text: string(link.Text(output))})
+			}
+		}
+		if !changed && len(edits) == 0 {
+			continue
+		}
+		output = applyEdits(output, edits)
+
+		if err := os.WriteFile(file, output, 0644); err != nil {
+			return updated, fmt.Errorf("failed to write updated markdown to %s: %w", file, err)
+		}
+		updated = append(updated, file)
+	}
+
+	return updated, nil
+}
@@ -0,0 +1,265 @@
+package server
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	shingleSize               = 5
+	minHashCount              = 128
+	minHashBandRows           = 2
+	defaultDuplicateThreshold = 0.5
+)
+
+// DuplicatePair is two documents whose bodies overlap. Similarity is the
+// estimated Jaccard similarity of their word shingles; Overlap is the share
+// of the smaller document that also appears in the other.
+type DuplicatePair struct {
+	First      string  `json:"first"`
+	Second     string  `json:"second"`
+	Similarity float64 `json:"similarity"`
+	Overlap    float64 `json:"overlap"`
+}
+
+// DuplicateMatch is an existing document that overlaps some new content.
+// Overlap is the share of the new content that also appears in it.
+type DuplicateMatch struct {
+	Path       string  `json:"path"`
+	Similarity float64 `json:"similarity"`
+	Overlap    float64 `json:"overlap"`
+}
+
+// minHashSignature summarises the set of word shingles of a document so that
+// the Jaccard similarity of two documents can be estimated from their
+// signatures alone.
+type minHashSignature struct {
+	values [minHashCount]uint64
+	size   int
+}
+
+var minHashSeeds = func() [minHashCount]uint64 {
+	var seeds [minHashCount]uint64
+	for i := range seeds {
+		seeds[i] = mix64(uint64(i) + 1)
+	}
+	return seeds
+}()
+
+// mix64 is the splitmix64 finaliser, used to derive independent hash
+// functions from one shingle hash.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// minHash shingles the search terms of a document into overlapping runs of
+// five words and returns its signature, or nil when the document is too
+// short to compare meaningfully.
+func minHash(words []string) *minHashSignature {
+	if len(words) < shingleSize {
+		return nil
+	}
+
+	shingles := make(map[uint64]bool)
+	for i := 0; i+shingleSize <= len(words); i++ {
+		h := fnv.New64a()
+		h.Write([]byte(strings.Join(words[i:i+shingleSize], " ")))
+		shingles[h.Sum64()] = true
+	}
+
+	sig := &minHashSignature{size: len(shingles)}
+	for i := range sig.values {
+		sig.values[i] = math.MaxUint64
+	}
+	for shingle := range shingles {
+		for i, seed := range minHashSeeds {
+			if v := mix64(shingle ^ seed); v < sig.values[i] {
+				sig.values[i] = v
+			}
+		}
+	}
+	return sig
+}
+
+// bandKeys splits the signature into bands of minHashBandRows values and
+// hashes each band together with its position. Documents sharing a key are
+// candidates for comparison; with bands of two rows, a pair with a Jaccard
+// similarity of 0.2 shares one with a probability of about 93%.
+func (s *minHashSignature) bandKeys() []uint64 {
+	keys := make([]uint64, 0, minHashCount/minHashBandRows)
+	for band := 0; band < minHashCount/minHashBandRows; band++ {
+		key := mix64(uint64(band) + 1)
+		for _, v := range s.values[band*minHashBandRows : (band+1)*minHashBandRows] {
+			key = mix64(key ^ v)
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// similarity estimates the Jaccard similarity of the two shingle sets.
+func (s *minHashSignature) similarity(other *minHashSignature) float64 {
+	equal := 0
+	for i := range s.values {
+		if s.values[i] == other.values[i] {
+			equal++
+		}
+	}
+	return float64(equal) / minHashCount
+}
+
+// containment estimates the share of s's shingles that also belong to other,
+// derived from their Jaccard similarity and set sizes.
+func (s *minHashSignature) containment(other *minHashSignature, jaccard float64) float64 {
+	c := jaccard * float64(s.size+other.size) / ((1 + jaccard) * float64(s.size))
+	return math.Min(c, 1)
+}
+
+// candidates returns the indexed documents sharing at least one band with
+// sig.
+func (idx *SearchIndex) candidates(sig *minHashSignature) map[string]bool {
+	found := make(map[string]bool)
+	for _, key := range sig.bandKeys() {
+		for path := range idx.bands[key] {
+			found[path] = true
+		}
+	}
+	return found
+}
+
+func roundScore(x float64) float64 {
+	return math.Round(x*100) / 100
+}
+
+// FindDuplicatesLogic finds the pairs of markdown documents under root/path,
+// or all of root when path is empty, whose overlap reaches threshold, most
+// overlapping first. Only documents sharing a MinHash band are compared.
+// Generated index files are ignored. threshold defaults to 0.5 when not
+// positive.
+func FindDuplicatesLogic(root, path string, threshold float64) ([]DuplicatePair, error) {
+	if threshold <= 0 {
+		threshold = defaultDuplicateThreshold
+	}
+	prefix := ""
+	if path != "" {
+		folder, err := resolveInRoot(root, path)
+		if err != nil {
+			return nil, err
+		}
+		if prefix = rootRelPath(root, folder); prefix != "" {
+			prefix += "/"
+		}
+	}
+
+	idx, err := searchIndexFor(root)
+	if err != nil {
+		return nil, err
+	}
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	pairs := []DuplicatePair{}
+	for a, sigA := range idx.signatures {
+		if !strings.HasPrefix(a, prefix) {
+			continue
+		}
+		for b := range idx.candidates(sigA) {
+			if b <= a || !strings.HasPrefix(b, prefix) {
+				continue
+			}
+			sigB := idx.signatures[b]
+			jaccard := sigA.similarity(sigB)
+			smaller, larger := sigA, sigB
+			if sigB.size < sigA.size {
+				smaller, larger = sigB, sigA
+			}
+			overlap := smaller.containment(larger, jaccard)
+			if overlap < threshold {
+				continue
+			}
+			pairs = append(pairs, DuplicatePair{First: a, Second: b, Similarity: roundScore(jaccard), Overlap: roundScore(overlap)})
+		}
+	}
+
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].Overlap != pairs[j].Overlap {
+			return pairs[i].Overlap > pairs[j].Overlap
+		}
+		if pairs[i].First != pairs[j].First {
+			return pairs[i].First < pairs[j].First
+		}
+		return pairs[i].Second < pairs[j].Second
+	})
+	return pairs, nil
+}
+
+// CheckDuplicatesLogic returns the documents under root that the given
+// content substantially overlaps, most overlapping first, so that an agent
+// can edit an existing document instead of creating a new one. exclude is a
+// file to leave out, typically the one about to be written. Signatures come
+// from the shared search index, so documents are not read again.
+func CheckDuplicatesLogic(root, content, exclude string, threshold float64) ([]DuplicateMatch, error) {
+	if threshold <= 0 {
+		threshold = defaultDuplicateThreshold
+	}
+	matches := []DuplicateMatch{}
+	sig := minHash(analyzeDocument("", []byte(content)).Terms)
+	if sig == nil {
+		return matches, nil
+	}
+	if _, err := os.Stat(root); os.IsNotExist(err) {
+		return matches, nil
+	}
+
+	idx, err := searchIndexFor(root)
+	if err != nil {
+		return nil, err
+	}
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	excluded := ""
+	if exclude != "" {
+		excluded = rootRelPath(root, exclude)
+	}
+
+	for path := range idx.candidates(sig) {
+		if path == excluded {
+			continue
+		}
+		other := idx.signatures[path]
+		jaccard := sig.similarity(other)
+		overlap := sig.containment(other, jaccard)
+		if overlap < threshold && jaccard < threshold {
+			continue
+		}
+		matches = append(matches, DuplicateMatch{Path: path, Similarity: roundScore(jaccard), Overlap: roundScore(overlap)})
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Overlap != matches[j].Overlap {
+			return matches[i].Overlap > matches[j].Overlap
+		}
+		return matches[i].Path < matches[j].Path
+	})
+	return matches, nil
+}
+
+// duplicateWarning describes matches as a warning for a tool result.
+func duplicateWarning(root string, matches []DuplicateMatch) string {
+	parts := []string{}
+	for _, m := range matches {
+		parts = append(parts, fmt.Sprintf("%s (%.0f%% overlap)", filepath.ToSlash(filepath.Join(root, m.Path)), m.Overlap*100))
+	}
+	return "Possible duplicates: " + strings.Join(parts, ", ") + "; consider editing or merging into the existing document instead of creating a new one"
+}
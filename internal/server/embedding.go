@@ -0,0 +1,194 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+)
+
+const defaultEmbeddingDimensions = 256
+
+// Embedder turns texts into vectors whose cosine similarity reflects how
+// close the texts are in meaning. Name identifies the model, so vectors from
+// different embedders are never compared.
+type Embedder interface {
+	Name() string
+	Embed(texts []string) ([][]float32, error)
+}
+
+// EmbeddingOptions selects the embedder used by semantic search. Without a
+// URL the offline HashEmbedder is used.
+type EmbeddingOptions struct {
+	// URL is an OpenAI-compatible embeddings endpoint.
+	URL string
+	// Model is sent with every request and names the vector store.
+	Model string
+	// APIKey is sent as a bearer token when set.
+	APIKey string
+}
+
+// embedderFor returns the embedder described by opts.
+func embedderFor(opts EmbeddingOptions) Embedder {
+	if opts.URL == "" {
+		return HashEmbedder{}
+	}
+	return &HTTPEmbedder{URL: opts.URL, Model: opts.Model, APIKey: opts.APIKey}
+}
+
+// HashEmbedder is an offline embedder that hashes words, word pairs and
+// character trigrams into a fixed number of dimensions. Trigrams let
+// inflections of a word ("rotate", "rotating") land close together.
+type HashEmbedder struct {
+	// Dimensions defaults to 256.
+	Dimensions int
+}
+
+func (e HashEmbedder) dimensions() int {
+	if e.Dimensions > 0 {
+		return e.Dimensions
+	}
+	return defaultEmbeddingDimensions
+}
+
+func (e HashEmbedder) Name() string {
+	return fmt.Sprintf("hash-%d", e.dimensions())
+}
+
+func (e HashEmbedder) Embed(texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i] = e.embed(text)
+	}
+	return vectors, nil
+}
+
+func (e HashEmbedder) embed(text string) []float32 {
+	v := make([]float64, e.dimensions())
+	add := func(feature string, weight float64) {
+		h := fnv.New64a()
+		h.Write([]byte(feature))
+		sum := h.Sum64()
+		if sum>>63 == 1 {
+			weight = -weight
+		}
+		v[sum%uint64(len(v))] += weight
+	}
+
+	words := tokenize(text)
+	for i, word := range words {
+		add("w:"+word, 1)
+		if i+1 < len(words) {
+			add("b:"+word+" "+words[i+1], 0.5)
+		}
+		runes := []rune("<" + word + ">")
+		for j := 0; j+3 <= len(runes); j++ {
+			add("c:"+string(runes[j:j+3]), 0.5)
+		}
+	}
+
+	return normalizeVector(v)
+}
+
+func normalizeVector(v []float64) []float32 {
+	norm := 0.0
+	for _, x := range v {
+		norm += x * x
+	}
+	norm = math.Sqrt(norm)
+
+	out := make([]float32, len(v))
+	if norm == 0 {
+		return out
+	}
+	for i, x := range v {
+		out[i] = float32(x / norm)
+	}
+	return out
+}
+
+// cosineSimilarity compares two vectors of the same length.
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(normA*normB)
+}
+
+// HTTPEmbedder calls an OpenAI-compatible embeddings endpoint, posting
+// {"model", "input"} and reading {"data": [{"index", "embedding"}]}.
+type HTTPEmbedder struct {
+	URL    string
+	Model  string
+	APIKey string
+	Client *http.Client
+}
+
+func (e *HTTPEmbedder) Name() string {
+	return "http-" + folderName(e.Model)
+}
+
+func (e *HTTPEmbedder) Embed(texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return [][]float32{}, nil
+	}
+
+	body, err := json.Marshal(map[string]any{"model": e.Model, "input": texts})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, e.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if e.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+e.APIKey)
+	}
+
+	client := e.Client
+	if client == nil {
+		client = &http.Client{Timeout: 60 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("embedding request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("embedding request failed: %s", resp.Status)
+	}
+
+	var decoded struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		return nil, fmt.Errorf("failed to decode embeddings: %w", err)
+	}
+	if len(decoded.Data) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(decoded.Data))
+	}
+
+	sort.SliceStable(decoded.Data, func(i, j int) bool { return decoded.Data[i].Index < decoded.Data[j].Index })
+	vectors := make([][]float32, len(texts))
+	for i, d := range decoded.Data {
+		vectors[i] = d.Embedding
+	}
+	return vectors, nil
+}
@@ -0,0 +1,235 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+const (
+	GraphFormatJSON    = "json"
+	GraphFormatDOT     = "dot"
+	GraphFormatMermaid = "mermaid"
+)
+
+// ExportOptions selects what ExportGraphLogic emits.
+type ExportOptions struct {
+	// Format is GraphFormatJSON (default), GraphFormatDOT or
+	// GraphFormatMermaid.
+	Format string
+	// Cluster groups documents by folder.
+	Cluster bool
+	// Path limits the graph to a folder relative to the root.
+	Path string
+	// Tag limits the graph to documents with this tag.
+	Tag string
+}
+
+// ExportNode is a document in an exported graph.
+type ExportNode struct {
+	Path   string   `json:"path"`
+	Title  string   `json:"title"`
+	Folder string   `json:"folder"`
+	Tags   []string `json:"tags"`
+}
+
+// GraphExport is the link graph as nodes and edges. Only links between
+// exported documents are kept.
+type GraphExport struct {
+	Nodes []ExportNode `json:"nodes"`
+	Edges []GraphEdge  `json:"edges"`
+}
+
+// Export returns the documents matching opts and the links between them.
+func (g *LinkGraph) Export(opts ExportOptions) *GraphExport {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	prefix := strings.Trim(path.Clean("/"+strings.ReplaceAll(opts.Path, "\\", "/")), "/")
+	tag := strings.ToLower(strings.TrimPrefix(opts.Tag, "#"))
+
+	export := &GraphExport{Nodes: []ExportNode{}, Edges: []GraphEdge{}}
+	included := make(map[string]bool)
+	docs := g.sortedDocs()
+	for _, doc := range docs {
+		if prefix != "" && !strings.HasPrefix(doc.Path, prefix+"/") {
+			continue
+		}
+		if tag != "" && !containsString(doc.Tags, tag) {
+			continue
+		}
+		included[doc.Path] = true
+		folder := path.Dir(doc.Path)
+		if folder == "." {
+			folder = ""
+		}
+		export.Nodes = append(export.Nodes, ExportNode{Path: doc.Path, Title: doc.Title, Folder: folder, Tags: doc.Tags})
+	}
+
+	for _, doc := range docs {
+		if !included[doc.Path] {
+			continue
+		}
+		for _, link := range doc.Links {
+			if included[link.Target] && link.Target != doc.Path {
+				export.Edges = append(export.Edges, GraphEdge{Source: doc.Path, Target: link.Target, Text: link.Text})
+			}
+		}
+	}
+	return export
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// uniqueEdges drops repeated links between the same two documents.
+func (e *GraphExport) uniqueEdges() [][2]string {
+	seen := make(map[[2]string]bool)
+	edges := [][2]string{}
+	for _, edge := range e.Edges {
+		key := [2]string{edge.Source, edge.Target}
+		if !seen[key] {
+			seen[key] = true
+			edges = append(edges, key)
+		}
+	}
+	return edges
+}
+
+// folders returns the folders of the exported documents, sorted, with the
+// documents in each.
+func (e *GraphExport) folders() ([]string, map[string][]ExportNode) {
+	byFolder := make(map[string][]ExportNode)
+	for _, node := range e.Nodes {
+		byFolder[node.Folder] = append(byFolder[node.Folder], node)
+	}
+	folders := make([]string, 0, len(byFolder))
+	for folder := range byFolder {
+		folders = append(folders, folder)
+	}
+	sort.Strings(folders)
+	return folders, byFolder
+}
+
+// DOT renders the graph for Graphviz. With cluster set every folder becomes
+// a cluster subgraph.
+func (e *GraphExport) DOT(cluster bool) string {
+	quote := func(s string) string {
+		return `"` + strings.ReplaceAll(strings.ReplaceAll(s, `\`, `\\`), `"`, `\"`) + `"`
+	}
+
+	var b strings.Builder
+	b.WriteString("digraph docs {\n  rankdir=LR;\n  node [shape=box];\n")
+	writeNode := func(indent string, node ExportNode) {
+		fmt.Fprintf(&b, "%s%s [label=%s];\n", indent, quote(node.Path), quote(node.Title))
+	}
+	if cluster {
+		folders, byFolder := e.folders()
+		clusters := 0
+		for _, folder := range folders {
+			if folder == "" {
+				for _, node := range byFolder[folder] {
+					writeNode("  ", node)
+				}
+				continue
+			}
+			fmt.Fprintf(&b, "  subgraph cluster_%d {\n    label=%s;\n", clusters, quote(folder+"/"))
+			clusters++
+			for _, node := range byFolder[folder] {
+				writeNode("    ", node)
+			}
+			b.WriteString("  }\n")
+		}
+	} else {
+		for _, node := range e.Nodes {
+			writeNode("  ", node)
+		}
+	}
+	for _, edge := range e.uniqueEdges() {
+		fmt.Fprintf(&b, "  %s -> %s;\n", quote(edge[0]), quote(edge[1]))
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// Mermaid renders the graph as a Mermaid flowchart. With cluster set every
+// folder becomes a subgraph.
+func (e *GraphExport) Mermaid(cluster bool) string {
+	ids := make(map[string]string)
+	for i, node := range e.Nodes {
+		ids[node.Path] = fmt.Sprintf("n%d", i)
+	}
+	label := func(s string) string {
+		return `["` + strings.ReplaceAll(s, `"`, "#quot;") + `"]`
+	}
+
+	var b strings.Builder
+	b.WriteString("flowchart LR\n")
+	writeNode := func(indent string, node ExportNode) {
+		fmt.Fprintf(&b, "%s%s%s\n", indent, ids[node.Path], label(node.Title))
+	}
+	if cluster {
+		folders, byFolder := e.folders()
+		clusters := 0
+		for _, folder := range folders {
+			if folder == "" {
+				for _, node := range byFolder[folder] {
+					writeNode("  ", node)
+				}
+				continue
+			}
+			fmt.Fprintf(&b, "  subgraph c%d%s\n", clusters, label(folder+"/"))
+			clusters++
+			for _, node := range byFolder[folder] {
+				writeNode("    ", node)
+			}
+			b.WriteString("  end\n")
+		}
+	} else {
+		for _, node := range e.Nodes {
+			writeNode("  ", node)
+		}
+	}
+	for _, edge := range e.uniqueEdges() {
+		fmt.Fprintf(&b, "  %s --> %s\n", ids[edge[0]], ids[edge[1]])
+	}
+	return b.String()
+}
+
+// ExportGraphLogic renders the link graph of the documents under root in the
+// format chosen by opts.
+func ExportGraphLogic(root string, opts ExportOptions) (string, error) {
+	g, err := linkGraphFor(root)
+	if err != nil {
+		return "", err
+	}
+	if opts.Path != "" {
+		if _, err := resolveInRoot(root, opts.Path); err != nil {
+			return "", err
+		}
+	}
+	export := g.Export(opts)
+
+	switch opts.Format {
+	case "", GraphFormatJSON:
+		data, err := json.MarshalIndent(export, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	case GraphFormatDOT:
+		return export.DOT(opts.Cluster), nil
+	case GraphFormatMermaid:
+		return export.Mermaid(opts.Cluster), nil
+	default:
+		return "", fmt.Errorf("unknown format %q: use %s, %s or %s", opts.Format, GraphFormatJSON, GraphFormatDOT, GraphFormatMermaid)
+	}
+}
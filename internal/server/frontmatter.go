@@ -0,0 +1,120 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+	"go.abhg.dev/goldmark/hashtag"
+	"gopkg.in/yaml.v3"
+)
+
+// splitFrontmatter separates a leading YAML frontmatter block delimited by
+// "---" lines from the markdown body. Documents without frontmatter, or with
+// frontmatter that is not valid YAML, return a nil map and the full source.
+func splitFrontmatter(source []byte) (map[string]any, []byte) {
+	normalized := bytes.ReplaceAll(source, []byte("\r\n"), []byte("\n"))
+	if !bytes.HasPrefix(normalized, []byte("---\n")) {
+		return nil, source
+	}
+
+	rest := normalized[len("---\n"):]
+	end := bytes.Index(rest, []byte("\n---"))
+	if end < 0 {
+		return nil, source
+	}
+
+	body := rest[end+len("\n---"):]
+	if i := bytes.IndexByte(body, '\n'); i >= 0 {
+		body = body[i+1:]
+	} else {
+		body = nil
+	}
+
+	meta := make(map[string]any)
+	if err := yaml.Unmarshal(rest[:end], &meta); err != nil {
+		return nil, source
+	}
+
+	return meta, body
+}
+
+// frontmatterLines returns the number of lines before body, as returned by
+// splitFrontmatter for source. body is cut from the source with CRLF line
+// endings normalized, so its length cannot be compared with the source's.
+func frontmatterLines(source, body []byte) int {
+	normalized := bytes.ReplaceAll(source, []byte("\r\n"), []byte("\n"))
+	if len(body) > len(normalized) {
+		return 0
+	}
+	return bytes.Count(normalized[:len(normalized)-len(body)], []byte("\n"))
+}
+
+// frontmatterValue returns the frontmatter value for key as a string. Lists
+// yield their first element.
+func frontmatterValue(meta map[string]any, key string) string {
+	switch v := meta[key].(type) {
+	case nil:
+		return ""
+	case string:
+		return strings.TrimSpace(v)
+	case []any:
+		if len(v) == 0 {
+			return ""
+		}
+		return frontmatterValue(map[string]any{key: v[0]}, key)
+	default:
+		return strings.TrimSpace(fmt.Sprint(v))
+	}
+}
+
+// hashtags returns the #hashtags in a markdown body ordered by how often they
+// occur, most frequent first. Ties keep the order of first appearance.
+func hashtags(body []byte) []string {
+	md := goldmark.New(goldmark.WithExtensions(&hashtag.Extender{}))
+	doc := md.Parser().Parse(text.NewReader(body))
+
+	counts := make(map[string]int)
+	order := []string{}
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if tag, ok := n.(*hashtag.Node); ok && entering {
+			name := strings.ToLower(string(tag.Tag))
+			if counts[name] == 0 {
+				order = append(order, name)
+			}
+			counts[name]++
+		}
+		return ast.WalkContinue, nil
+	})
+
+	for i := 1; i < len(order); i++ {
+		for j := i; j > 0 && counts[order[j]] > counts[order[j-1]]; j-- {
+			order[j], order[j-1] = order[j-1], order[j]
+		}
+	}
+
+	return order
+}
+
+// folderName turns a free-form category or tag into a lowercase folder name
+// made of letters, digits and dashes.
+func folderName(value string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(value) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,293 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// GitOptions configures automatic commits of the changes made by tools.
+type GitOptions struct {
+	// AutoCommit commits every mutating tool call to the local repository.
+	AutoCommit bool
+	// Branch is the branch commits go to, created from HEAD if needed. It
+	// is never checked out: HEAD and the working tree stay as they are.
+	// Empty means the current branch.
+	Branch string
+	// AuthorName and AuthorEmail override the identity from the git config.
+	AuthorName  string
+	AuthorEmail string
+}
+
+const maxCommitArgLength = 200
+
+// git runs the git CLI in dir and returns its trimmed standard output.
+func git(dir string, args ...string) (string, error) {
+	return gitEnv(dir, nil, args...)
+}
+
+// gitEnv runs git like git, with env added to the environment.
+func gitEnv(dir string, env []string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// gitToplevel returns the root of the working tree containing path.
+func gitToplevel(path string) (string, error) {
+	dir, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	for {
+		if top, err := git(dir, "rev-parse", "--show-toplevel"); err == nil {
+			return top, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", fmt.Errorf("%s is not inside a git repository", path)
+		}
+		dir = parent
+	}
+}
+
+// CommitOperationLogic commits the files touched by op to the local git
+// repository that contains them, with a message describing the tool, its
+// arguments and the affected files. It returns the new commit hash, or an
+// empty string when git saw nothing to commit, and the touched files git
+// ignores, which are left out of the commit.
+//
+// With a branch other than the checked out one, the commit is built in a
+// temporary index and the branch ref is moved to it, so that HEAD, the
+// index and the working tree of the user are left alone.
+func CommitOperationLogic(op *Operation, opts GitOptions) (string, []string, error) {
+	if op == nil || len(op.Files) == 0 {
+		return "", nil, nil
+	}
+
+	top, err := gitToplevel(filepath.Dir(op.Files[0].Path))
+	if err != nil {
+		return "", nil, err
+	}
+
+	paths := []string{}
+	for _, change := range op.Files {
+		abs, err := filepath.Abs(change.Path)
+		if err != nil {
+			return "", nil, err
+		}
+		paths = append(paths, abs)
+	}
+	paths, ignored := withoutIgnored(top, paths)
+	if len(paths) == 0 {
+		return "", ignored, nil
+	}
+
+	if opts.Branch != "" {
+		current, err := git(top, "symbolic-ref", "--short", "HEAD")
+		if err != nil || current != opts.Branch {
+			hash, err := commitToBranch(top, opts, commitMessage(op, top), paths)
+			return hash, ignored, err
+		}
+	}
+
+	if _, err := git(top, append([]string{"add", "-A", "--"}, paths...)...); err != nil {
+		return "", ignored, err
+	}
+	if _, err := git(top, append([]string{"diff", "--cached", "--quiet", "--"}, paths...)...); err == nil {
+		return "", ignored, nil
+	}
+
+	args := append(identityArgs(opts), "commit", "-m", commitMessage(op, top), "--")
+	if _, err := git(top, append(args, paths...)...); err != nil {
+		return "", ignored, err
+	}
+
+	hash, err := git(top, "rev-parse", "HEAD")
+	return hash, ignored, err
+}
+
+// withoutIgnored splits paths into those git tracks or may track and those
+// it ignores, relative to the working tree at top.
+func withoutIgnored(top string, paths []string) ([]string, []string) {
+	// check-ignore exits with 1 when no path is ignored.
+	out, _ := git(top, append([]string{"check-ignore", "--"}, paths...)...)
+	if out == "" {
+		return paths, nil
+	}
+	ignoredSet := make(map[string]bool)
+	for _, line := range strings.Split(out, "\n") {
+		if !filepath.IsAbs(line) {
+			line = filepath.Join(top, line)
+		}
+		ignoredSet[filepath.Clean(line)] = true
+	}
+
+	kept, ignored := []string{}, []string{}
+	for _, path := range paths {
+		if ignoredSet[filepath.Clean(path)] {
+			rel, err := filepath.Rel(top, path)
+			if err != nil {
+				rel = path
+			}
+			ignored = append(ignored, filepath.ToSlash(rel))
+			continue
+		}
+		kept = append(kept, path)
+	}
+	return kept, ignored
+}
+
+// commitToBranch commits paths, as they are in the working tree, on top of
+// branch without checking it out. A missing branch starts from HEAD.
+func commitToBranch(top string, opts GitOptions, message string, paths []string) (string, error) {
+	ref := "refs/heads/" + opts.Branch
+	if _, err := git(top, "check-ref-format", ref); err != nil {
+		return "", fmt.Errorf("invalid branch %q", opts.Branch)
+	}
+	parent, err := git(top, "rev-parse", "--verify", "--quiet", ref+"^{commit}")
+	oldValue := parent
+	if err != nil {
+		parent, _ = git(top, "rev-parse", "--verify", "--quiet", "HEAD^{commit}")
+		oldValue = ""
+	}
+
+	index, err := os.CreateTemp("", "doc-mcp-index-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary index: %w", err)
+	}
+	index.Close()
+	defer os.Remove(index.Name())
+	// git refuses an empty file as an index; it creates the file itself.
+	os.Remove(index.Name())
+	env := []string{"GIT_INDEX_FILE=" + index.Name()}
+
+	if parent != "" {
+		if _, err := gitEnv(top, env, "read-tree", parent); err != nil {
+			return "", err
+		}
+	}
+	existing, missing := []string{}, []string{}
+	for _, path := range paths {
+		if _, err := os.Lstat(path); err == nil {
+			existing = append(existing, path)
+		} else {
+			missing = append(missing, path)
+		}
+	}
+	if len(existing) > 0 {
+		if _, err := gitEnv(top, env, append([]string{"add", "-A", "--"}, existing...)...); err != nil {
+			return "", err
+		}
+	}
+	if len(missing) > 0 {
+		if _, err := gitEnv(top, env, append([]string{"rm", "-r", "-q", "--cached", "--ignore-unmatch", "--"}, missing...)...); err != nil {
+			return "", err
+		}
+	}
+	tree, err := gitEnv(top, env, "write-tree")
+	if err != nil {
+		return "", err
+	}
+
+	args := append(identityArgs(opts), "commit-tree", tree, "-m", message)
+	if parent != "" {
+		if parentTree, err := git(top, "rev-parse", parent+"^{tree}"); err == nil && parentTree == tree {
+			return "", nil
+		}
+		args = append(args, "-p", parent)
+	}
+	hash, err := git(top, args...)
+	if err != nil {
+		return "", err
+	}
+	if _, err := git(top, "update-ref", "-m", "doc-mcp: commit", ref, hash, oldValue); err != nil {
+		return "", err
+	}
+	return hash, nil
+}
+
+// identityArgs returns the -c options setting the configured commit
+// identity.
+func identityArgs(opts GitOptions) []string {
+	args := []string{}
+	if opts.AuthorName != "" {
+		args = append(args, "-c", "user.name="+opts.AuthorName)
+	}
+	if opts.AuthorEmail != "" {
+		args = append(args, "-c", "user.email="+opts.AuthorEmail)
+	}
+	return args
+}
+
+func commitMessage(op *Operation, top string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "doc-mcp: %s (%d files)\n\n", op.Tool, len(op.Files))
+
+	if args := commitArgs(op.Args); args != "" {
+		fmt.Fprintf(&b, "Arguments: %s\n\n", args)
+	}
+
+	b.WriteString("Files:\n")
+	for _, change := range op.Files {
+		status := "modified"
+		switch {
+		case change.Before == "":
+			status = "created"
+		case change.After == "":
+			status = "deleted"
+		}
+
+		path := change.Path
+		if abs, err := filepath.Abs(change.Path); err == nil {
+			if rel, err := filepath.Rel(top, abs); err == nil {
+				path = rel
+			}
+		}
+		fmt.Fprintf(&b, "- %s (%s)\n", filepath.ToSlash(path), status)
+	}
+
+	return b.String()
+}
+
+// commitArgs renders tool arguments as compact JSON, shortening long string
+// values such as file contents.
+func commitArgs(args any) string {
+	if args == nil {
+		return ""
+	}
+	data, err := json.Marshal(args)
+	if err != nil {
+		return ""
+	}
+
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return string(data)
+	}
+	for key, value := range fields {
+		if s, ok := value.(string); ok && len(s) > maxCommitArgLength {
+			cut := maxCommitArgLength
+			for cut > 0 && !utf8.RuneStart(s[cut]) {
+				cut--
+			}
+			fields[key] = s[:cut] + "..."
+		}
+	}
+	data, _ = json.Marshal(fields)
+	return string(data)
+}
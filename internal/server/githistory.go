@@ -0,0 +1,282 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DocCommit is one commit in the history of a document.
+type DocCommit struct {
+	Hash    string `json:"hash"`
+	Author  string `json:"author"`
+	Email   string `json:"email"`
+	Date    string `json:"date"`
+	Subject string `json:"subject"`
+}
+
+// DiffHunk is one hunk of a unified diff with its lines numbered.
+type DiffHunk struct {
+	OldStart int        `json:"old_start"`
+	OldLines int        `json:"old_lines"`
+	NewStart int        `json:"new_start"`
+	NewLines int        `json:"new_lines"`
+	Section  string     `json:"section,omitempty"`
+	Lines    []DiffLine `json:"lines"`
+}
+
+// DiffLine is a context, added or removed line of a hunk. OldLine and
+// NewLine are zero on the side the line does not exist.
+type DiffLine struct {
+	Kind    string `json:"kind"`
+	OldLine int    `json:"old_line,omitempty"`
+	NewLine int    `json:"new_line,omitempty"`
+	Text    string `json:"text"`
+}
+
+// BlameLine attributes one line of a document to the commit that last
+// changed it.
+type BlameLine struct {
+	Line    int    `json:"line"`
+	Hash    string `json:"hash"`
+	Author  string `json:"author"`
+	Email   string `json:"email"`
+	Date    string `json:"date"`
+	Summary string `json:"summary"`
+	Text    string `json:"text"`
+}
+
+var hunkHeaderRe = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@ ?(.*)$`)
+
+// gitDocument resolves a path relative to root and returns it as an
+// absolute path along with the top of the git working tree containing it.
+func gitDocument(root, path string) (string, string, error) {
+	file, err := resolveInRoot(root, path)
+	if err != nil {
+		return "", "", err
+	}
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return "", "", err
+	}
+	top, err := gitToplevel(filepath.Dir(abs))
+	if err != nil {
+		return "", "", err
+	}
+	return abs, top, nil
+}
+
+// DocHistoryLogic returns the commits that touched a document under root,
+// newest first, following renames. limit caps the number of commits when
+// positive.
+func DocHistoryLogic(root, path string, limit int) ([]DocCommit, error) {
+	abs, top, err := gitDocument(root, path)
+	if err != nil {
+		return nil, err
+	}
+
+	args := []string{"log", "--follow", "--format=%H%x1f%an%x1f%ae%x1f%aI%x1f%s"}
+	if limit > 0 {
+		args = append(args, "-n", strconv.Itoa(limit))
+	}
+	out, err := git(top, append(args, "--", abs)...)
+	if err != nil {
+		return nil, err
+	}
+
+	commits := []DocCommit{}
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Split(line, "\x1f")
+		if len(fields) != 5 {
+			continue
+		}
+		commits = append(commits, DocCommit{
+			Hash:    fields[0],
+			Author:  fields[1],
+			Email:   fields[2],
+			Date:    fields[3],
+			Subject: fields[4],
+		})
+	}
+	return commits, nil
+}
+
+// DocDiffLogic diffs a document under root between two revisions. from
+// defaults to HEAD; an empty to compares against the working tree.
+func DocDiffLogic(root, path, from, to string) ([]DiffHunk, error) {
+	abs, top, err := gitDocument(root, path)
+	if err != nil {
+		return nil, err
+	}
+
+	if from == "" {
+		from = "HEAD"
+	}
+	fromHash, err := resolveCommit(top, from)
+	if err != nil {
+		return nil, err
+	}
+	args := []string{"diff", "--no-color", "--no-ext-diff", fromHash}
+	if to != "" {
+		toHash, err := resolveCommit(top, to)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, toHash)
+	}
+	out, err := git(top, append(args, "--", abs)...)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseUnifiedDiff(out), nil
+}
+
+// resolveCommit resolves a revision given by a tool call to a commit hash,
+// so that only hashes reach the git command line. Values that look like
+// options are rejected outright.
+func resolveCommit(top, rev string) (string, error) {
+	if strings.HasPrefix(rev, "-") {
+		return "", fmt.Errorf("invalid revision %q", rev)
+	}
+	hash, err := git(top, "rev-parse", "--verify", "--quiet", "--end-of-options", rev+"^{commit}")
+	if err != nil || hash == "" {
+		return "", fmt.Errorf("unknown revision %q", rev)
+	}
+	return hash, nil
+}
+
+func parseUnifiedDiff(diff string) []DiffHunk {
+	hunks := []DiffHunk{}
+	var hunk *DiffHunk
+	oldLine, newLine := 0, 0
+
+	scanner := bufio.NewScanner(strings.NewReader(diff))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if m := hunkHeaderRe.FindStringSubmatch(line); m != nil {
+			hunks = append(hunks, DiffHunk{
+				OldStart: atoiOr(m[1], 0),
+				OldLines: atoiOr(m[2], 1),
+				NewStart: atoiOr(m[3], 0),
+				NewLines: atoiOr(m[4], 1),
+				Section:  m[5],
+				Lines:    []DiffLine{},
+			})
+			hunk = &hunks[len(hunks)-1]
+			oldLine, newLine = hunk.OldStart, hunk.NewStart
+			continue
+		}
+		if hunk == nil || line == "" {
+			continue
+		}
+
+		switch line[0] {
+		case ' ':
+			hunk.Lines = append(hunk.Lines, DiffLine{Kind: "context", OldLine: oldLine, NewLine: newLine, Text: line[1:]})
+			oldLine++
+			newLine++
+		case '-':
+			hunk.Lines = append(hunk.Lines, DiffLine{Kind: "removed", OldLine: oldLine, Text: line[1:]})
+			oldLine++
+		case '+':
+			hunk.Lines = append(hunk.Lines, DiffLine{Kind: "added", NewLine: newLine, Text: line[1:]})
+			newLine++
+		}
+	}
+
+	return hunks
+}
+
+func atoiOr(s string, fallback int) int {
+	if n, err := strconv.Atoi(s); err == nil {
+		return n
+	}
+	return fallback
+}
+
+// DocBlameLogic attributes every line of a document under root, as it is in
+// the working tree, to the commit that last changed it.
+func DocBlameLogic(root, path string) ([]BlameLine, error) {
+	abs, top, err := gitDocument(root, path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(abs); err != nil {
+		return nil, fmt.Errorf("%s not found: %w", path, err)
+	}
+
+	out, err := git(top, "blame", "--line-porcelain", "--", abs)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := []BlameLine{}
+	var current BlameLine
+	var authorTime int64
+	var authorTZ string
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "\t") {
+			current.Text = line[1:]
+			current.Date = blameDate(authorTime, authorTZ)
+			lines = append(lines, current)
+			current = BlameLine{}
+			continue
+		}
+
+		key, value, _ := strings.Cut(line, " ")
+		switch key {
+		case "author":
+			current.Author = value
+		case "author-mail":
+			current.Email = strings.Trim(value, "<>")
+		case "author-time":
+			authorTime, _ = strconv.ParseInt(value, 10, 64)
+		case "author-tz":
+			authorTZ = value
+		case "summary":
+			current.Summary = value
+		default:
+			fields := strings.Fields(line)
+			if len(fields) >= 3 && isObjectHash(fields[0]) {
+				current.Hash = fields[0]
+				current.Line = atoiOr(fields[2], 0)
+			}
+		}
+	}
+
+	return lines, nil
+}
+
+// isObjectHash reports whether s is a full git object name: 40 hex digits
+// for SHA-1 repositories, 64 for SHA-256 ones.
+func isObjectHash(s string) bool {
+	if len(s) != 40 && len(s) != 64 {
+		return false
+	}
+	for _, c := range s {
+		if !strings.ContainsRune("0123456789abcdef", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// blameDate formats a blame author time in the author's time zone.
+func blameDate(unix int64, tz string) string {
+	t := time.Unix(unix, 0).UTC()
+	if offset, err := time.Parse("-0700", tz); err == nil {
+		_, seconds := offset.Zone()
+		t = t.In(time.FixedZone(tz, seconds))
+	}
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,432 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+// LinkRef is a link seen from one of its ends. Path and Title describe the
+// document at the other end; Text, Line and Context locate the link in the
+// document it was written in. Missing marks links to documents that do not
+// exist.
+type LinkRef struct {
+	Path    string `json:"path"`
+	Title   string `json:"title,omitempty"`
+	Text    string `json:"text"`
+	Line    int    `json:"line"`
+	Context string `json:"context"`
+	Missing bool   `json:"missing,omitempty"`
+}
+
+// GraphNode is a document in the link graph. Distance is its number of hops
+// from the document a neighbourhood was asked for.
+type GraphNode struct {
+	Path     string `json:"path"`
+	Title    string `json:"title"`
+	Distance int    `json:"distance"`
+}
+
+// GraphEdge is a link from one document to another.
+type GraphEdge struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Text   string `json:"text"`
+}
+
+// Neighborhood is the part of the link graph within some hops of a document.
+type Neighborhood struct {
+	Path  string      `json:"path"`
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+// graphLink is an outgoing link as stored in the graph, with its target
+// relative to the root.
+type graphLink struct {
+	Target  string
+	Text    string
+	Line    int
+	Context string
+}
+
+// graphDoc is a document in the graph. Links combines its relative links
+// with its wiki links, which are kept unresolved in WikiLinks and resolved
+// against the whole graph whenever it changes.
+type graphDoc struct {
+	Path      string
+	Title     string
+	Tags      []string
+	Terms     []string
+	Links     []graphLink
+	MDLinks   []graphLink
+	WikiLinks []graphLink
+}
+
+// LinkGraph holds the relative links between the markdown files under a root
+// folder. It is built on first use and kept up to date by Update.
+type LinkGraph struct {
+	mu   sync.RWMutex
+	root string
+	docs map[string]*graphDoc
+}
+
+var (
+	linkGraphsMu sync.Mutex
+	linkGraphs   = make(map[string]*LinkGraph)
+)
+
+// NewLinkGraph returns an empty graph for the markdown files under root.
+func NewLinkGraph(root string) *LinkGraph {
+	return &LinkGraph{root: root, docs: make(map[string]*graphDoc)}
+}
+
+// linkGraphFor returns the shared graph for root, building it on first use.
+func linkGraphFor(root string) (*LinkGraph, error) {
+	linkGraphsMu.Lock()
+	defer linkGraphsMu.Unlock()
+
+	if g, ok := linkGraphs[root]; ok {
+		return g, nil
+	}
+	g := NewLinkGraph(root)
+	if err := g.Build(); err != nil {
+		return nil, err
+	}
+	linkGraphs[root] = g
+	return g, nil
+}
+
+// notifyLinkGraph brings the shared graph for root, if it has been built, up
+// to date with the given changed files or folders.
+func notifyLinkGraph(root string, paths []string) {
+	linkGraphsMu.Lock()
+	g, ok := linkGraphs[root]
+	linkGraphsMu.Unlock()
+	if ok {
+		g.Update(paths...)
+	}
+}
+
+// Build parses every markdown file under the root from scratch.
+func (g *LinkGraph) Build() error {
+	files, err := markdownFilesIn(g.root)
+	if err != nil {
+		return err
+	}
+
+	docs := make(map[string]*graphDoc)
+	for _, file := range files {
+		rel := rootRelPath(g.root, file)
+		if rel == "" {
+			continue
+		}
+		source, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read file %s: %w", file, err)
+		}
+		docs[rel] = g.analyze(rel, source)
+	}
+
+	g.mu.Lock()
+	g.docs = docs
+	g.resolveWikiLinks()
+	g.mu.Unlock()
+	return nil
+}
+
+// Update reparses the given files, or the documents in the given folders,
+// dropping the ones that no longer exist.
+func (g *LinkGraph) Update(paths ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	known := make([]string, 0, len(g.docs))
+	for rel := range g.docs {
+		known = append(known, rel)
+	}
+	for _, path := range changedDocuments(g.root, paths, known) {
+		rel := rootRelPath(g.root, path)
+		if rel == "" || !strings.HasSuffix(rel, ".md") || isTemplatePath(rel) {
+			continue
+		}
+		delete(g.docs, rel)
+		source, err := os.ReadFile(filepath.Join(g.root, filepath.FromSlash(rel)))
+		if err != nil {
+			continue
+		}
+		g.docs[rel] = g.analyze(rel, source)
+	}
+	g.resolveWikiLinks()
+}
+
+// resolveWikiLinks points the wiki links of every document at the documents
+// they name and recombines them with the relative links. Unresolved wiki
+// links keep their raw target, which matches no document.
+func (g *LinkGraph) resolveWikiLinks() {
+	resolver := newWikiResolver()
+	for _, doc := range g.docs {
+		resolver.add(doc.Path, doc.Title, doc.Path)
+	}
+
+	for _, doc := range g.docs {
+		doc.Links = append([]graphLink{}, doc.MDLinks...)
+		for _, link := range doc.WikiLinks {
+			if target := resolver.resolve(link.Target); target != "" {
+				link.Target = target
+			}
+			doc.Links = append(doc.Links, link)
+		}
+		sort.SliceStable(doc.Links, func(i, j int) bool { return doc.Links[i].Line < doc.Links[j].Line })
+	}
+}
+
+// analyze extracts the title, tags, terms, relative markdown links and wiki
+// links of a document. Relative links to files outside the root are left
+// out.
+func (g *LinkGraph) analyze(rel string, source []byte) *graphDoc {
+	meta, body := splitFrontmatter(source)
+	doc := &graphDoc{
+		Path:  rel,
+		Title: docTitle(source, rel),
+		Tags:  docTags(meta, body),
+		Terms: documentTerms(body),
+	}
+
+	dir := filepath.Dir(filepath.Join(g.root, filepath.FromSlash(rel)))
+	tree := newMarkdownParser().Parser().Parse(text.NewReader(source))
+	lines := strings.Split(string(source), "\n")
+	linkAt := func(n ast.Node, target string) graphLink {
+		line := nodeLine(n, source)
+		context := ""
+		if line > 0 && line <= len(lines) {
+			context = excerpt(strings.TrimSpace(lines[line-1]))
+		}
+		return graphLink{Target: target, Text: plainText(n, source), Line: line, Context: context}
+	}
+
+	// Links are listed in document order, so that reference links sharing
+	// a line and a definition keep their order.
+	doc.MDLinks = []graphLink{}
+	targets := relativeMarkdownLinks(tree, dir)
+	ast.Walk(tree, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		link, ok := n.(*ast.Link)
+		if !ok || !entering {
+			return ast.WalkContinue, nil
+		}
+		dest, ok := targets[link]
+		if !ok {
+			return ast.WalkContinue, nil
+		}
+		if target := rootRelPath(g.root, dest); target != "" {
+			doc.MDLinks = append(doc.MDLinks, linkAt(link, target))
+		}
+		return ast.WalkContinue, nil
+	})
+
+	doc.WikiLinks = []graphLink{}
+	for _, link := range wikiLinksIn(tree) {
+		doc.WikiLinks = append(doc.WikiLinks, linkAt(link, string(link.Target)))
+	}
+	return doc
+}
+
+// nodeLine returns the line, numbered from 1, of the first text inside n.
+// Links and images with no text of their own, as in [](x.md) or ![](x.png),
+// fall back to the first line of the block holding them. It returns 0 when
+// neither is found.
+func nodeLine(n ast.Node, source []byte) int {
+	line := 0
+	ast.Walk(n, func(child ast.Node, entering bool) (ast.WalkStatus, error) {
+		if t, ok := child.(*ast.Text); ok && entering {
+			line = bytes.Count(source[:t.Segment.Start], []byte("\n")) + 1
+			return ast.WalkStop, nil
+		}
+		return ast.WalkContinue, nil
+	})
+	if line > 0 {
+		return line
+	}
+	for p := n.Parent(); p != nil; p = p.Parent() {
+		if p.Type() == ast.TypeBlock && p.Lines().Len() > 0 {
+			return bytes.Count(source[:p.Lines().At(0).Start], []byte("\n")) + 1
+		}
+	}
+	return 0
+}
+
+// doc returns the document at path, given relative to the root.
+func (g *LinkGraph) doc(path string) (*graphDoc, error) {
+	file, err := resolveInRoot(g.root, path)
+	if err != nil {
+		return nil, err
+	}
+	doc, ok := g.docs[rootRelPath(g.root, file)]
+	if !ok {
+		return nil, fmt.Errorf("%s not found", path)
+	}
+	return doc, nil
+}
+
+// Outgoing returns the links written in the document at path.
+func (g *LinkGraph) Outgoing(path string) ([]LinkRef, error) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	doc, err := g.doc(path)
+	if err != nil {
+		return nil, err
+	}
+	refs := []LinkRef{}
+	for _, link := range doc.Links {
+		ref := LinkRef{Path: link.Target, Text: link.Text, Line: link.Line, Context: link.Context}
+		if target, ok := g.docs[link.Target]; ok {
+			ref.Title = target.Title
+		} else {
+			ref.Missing = true
+		}
+		refs = append(refs, ref)
+	}
+	return refs, nil
+}
+
+// Backlinks returns the links from other documents to the document at path.
+func (g *LinkGraph) Backlinks(path string) ([]LinkRef, error) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	doc, err := g.doc(path)
+	if err != nil {
+		return nil, err
+	}
+	refs := []LinkRef{}
+	for _, source := range g.sortedDocs() {
+		if source.Path == doc.Path {
+			continue
+		}
+		for _, link := range source.Links {
+			if link.Target == doc.Path {
+				refs = append(refs, LinkRef{Path: source.Path, Title: source.Title, Text: link.Text, Line: link.Line, Context: link.Context})
+			}
+		}
+	}
+	return refs, nil
+}
+
+// Neighbors returns the documents within depth links of the document at
+// path, following links in both directions, and the links between them.
+// depth defaults to 1 when not positive.
+func (g *LinkGraph) Neighbors(path string, depth int) (*Neighborhood, error) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	start, err := g.doc(path)
+	if err != nil {
+		return nil, err
+	}
+	if depth <= 0 {
+		depth = 1
+	}
+
+	adjacent := g.undirected()
+	distance := map[string]int{start.Path: 0}
+	frontier := []string{start.Path}
+	for d := 1; d <= depth && len(frontier) > 0; d++ {
+		next := []string{}
+		for _, p := range frontier {
+			for _, q := range adjacent[p] {
+				if _, seen := distance[q]; !seen {
+					distance[q] = d
+					next = append(next, q)
+				}
+			}
+		}
+		frontier = next
+	}
+
+	result := &Neighborhood{Path: start.Path, Nodes: []GraphNode{}, Edges: []GraphEdge{}}
+	for _, doc := range g.sortedDocs() {
+		d, ok := distance[doc.Path]
+		if !ok {
+			continue
+		}
+		result.Nodes = append(result.Nodes, GraphNode{Path: doc.Path, Title: doc.Title, Distance: d})
+		for _, link := range doc.Links {
+			if _, ok := distance[link.Target]; ok && link.Target != doc.Path {
+				result.Edges = append(result.Edges, GraphEdge{Source: doc.Path, Target: link.Target, Text: link.Text})
+			}
+		}
+	}
+	sort.SliceStable(result.Nodes, func(i, j int) bool { return result.Nodes[i].Distance < result.Nodes[j].Distance })
+	return result, nil
+}
+
+// undirected returns, for every document, the existing documents it links to
+// or is linked from.
+func (g *LinkGraph) undirected() map[string][]string {
+	seen := make(map[[2]string]bool)
+	adjacent := make(map[string][]string)
+	for _, doc := range g.sortedDocs() {
+		for _, link := range doc.Links {
+			if _, ok := g.docs[link.Target]; !ok || link.Target == doc.Path {
+				continue
+			}
+			key := [2]string{doc.Path, link.Target}
+			if link.Target < doc.Path {
+				key = [2]string{link.Target, doc.Path}
+			}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			adjacent[doc.Path] = append(adjacent[doc.Path], link.Target)
+			adjacent[link.Target] = append(adjacent[link.Target], doc.Path)
+		}
+	}
+	return adjacent
+}
+
+func (g *LinkGraph) sortedDocs() []*graphDoc {
+	docs := make([]*graphDoc, 0, len(g.docs))
+	for _, doc := range g.docs {
+		docs = append(docs, doc)
+	}
+	sort.Slice(docs, func(i, j int) bool { return docs[i].Path < docs[j].Path })
+	return docs
+}
+
+// GetBacklinksLogic returns the links to a document under root from the
+// other documents.
+func GetBacklinksLogic(root, path string) ([]LinkRef, error) {
+	g, err := linkGraphFor(root)
+	if err != nil {
+		return nil, err
+	}
+	return g.Backlinks(path)
+}
+
+// GetOutgoingLinksLogic returns the links written in a document under root.
+func GetOutgoingLinksLogic(root, path string) ([]LinkRef, error) {
+	g, err := linkGraphFor(root)
+	if err != nil {
+		return nil, err
+	}
+	return g.Outgoing(path)
+}
+
+// GetNeighborsLogic returns the documents under root within depth links of
+// a document, in either direction.
+func GetNeighborsLogic(root, path string, depth int) (*Neighborhood, error) {
+	g, err := linkGraphFor(root)
+	if err != nil {
+		return nil, err
+	}
+	return g.Neighbors(path, depth)
+}
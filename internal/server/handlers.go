@@ -2,15 +2,20 @@ package server
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"os"
 	"os/exec"
+	"path"
 	"strings"
 	"path/filepath"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/yuin/goldmark/text"
 )
 
 func CreateMarkdownFile(ctx context.Context, session *mcp.ServerSession, params *mcp.CallToolParamsFor[CreateMarkdownParams]) (*mcp.CallToolResultFor[any], error) {
-	warnings := validateMarkdown(params.Arguments.Content)
+	markdown := autoTOC(params.Arguments.Content)
+	warnings := validateMarkdown(markdown)
 
 	folder := params.Arguments.Path
 	if folder == "" {
@@ -46,6 +51,11 @@ func CreateMarkdownFile(ctx context.Context, session *mcp.ServerSession, params
 
 	filePath := filepath.Join(cwd, folder, params.Arguments.Name)
 
+	warnings = append(warnings, validateLinkedAnchors(markdown, filepath.Dir(filePath))...)
+	duplicates, _ := CheckDuplicatesLogic("doc", markdown, filePath, 0)
+
+	op := beginOperation("create_markdown_file", params.Arguments, operationScopes(filePath, folder)...)
+
 	f, err = os.Create(filePath)
 	if err != nil {
 		return &mcp.CallToolResultFor[any]{
@@ -55,7 +65,7 @@ func CreateMarkdownFile(ctx context.Context, session *mcp.ServerSession, params
 	}
 	defer f.Close()
 
-	_, err = f.WriteString(params.Arguments.Content)
+	_, err = f.WriteString(markdown)
 	if err != nil {
 		return &mcp.CallToolResultFor[any]{
 			Content: []mcp.Content{&mcp.TextContent{Text: "Failed to write file: " + err.Error()}},
@@ -67,10 +77,16 @@ func CreateMarkdownFile(ctx context.Context, session *mcp.ServerSession, params
 	cmd.Run()
 
 
-	content := []mcp.Content{&mcp.TextContent{Text: "File created successfully: " + filePath}}
+	refactored, created := autoRefactorContent(folder, filePath)
+	content := []mcp.Content{&mcp.TextContent{Text: "File created successfully: " + created}}
 	if len(warnings) > 0 {
 		content = append(content, &mcp.TextContent{Text: "Warnings: " + strings.Join(warnings, "; ")})
 	}
+	if len(duplicates) > 0 {
+		content = append(content, &mcp.TextContent{Text: duplicateWarning("doc", duplicates)})
+	}
+	content = append(content, refactored...)
+	content = append(content, op.finish()...)
 
 	return &mcp.CallToolResultFor[any]{
 		Content: content,
@@ -79,7 +95,9 @@ func CreateMarkdownFile(ctx context.Context, session *mcp.ServerSession, params
 }
 
 func EditMarkdownFile(ctx context.Context, session *mcp.ServerSession, params *mcp.CallToolParamsFor[EditMarkdownParams]) (*mcp.CallToolResultFor[any], error) {
-	warnings := validateMarkdown(params.Arguments.Content)
+	markdown := autoTOC(params.Arguments.Content)
+	warnings := validateMarkdown(markdown)
+	warnings = append(warnings, validateLinkedAnchors(markdown, filepath.Dir("doc/"+params.Arguments.Name))...)
 
 	err := os.MkdirAll("doc", 0755)
 	if err != nil {
@@ -89,6 +107,8 @@ func EditMarkdownFile(ctx context.Context, session *mcp.ServerSession, params *m
 		}, nil
 	}
 
+	op := beginOperation("edit_markdown_file", params.Arguments, "doc/"+params.Arguments.Name)
+
 	f, err := os.Create("doc/" + params.Arguments.Name)
 	if err != nil {
 		return &mcp.CallToolResultFor[any]{
@@ -98,7 +118,7 @@ func EditMarkdownFile(ctx context.Context, session *mcp.ServerSession, params *m
 	}
 	defer f.Close()
 
-	_, err = f.WriteString(params.Arguments.Content)
+	_, err = f.WriteString(markdown)
 	if err != nil {
 		return &mcp.CallToolResultFor[any]{
 			Content: []mcp.Content{&mcp.TextContent{Text: "Failed to write file: " + err.Error()}},
@@ -113,6 +133,7 @@ func EditMarkdownFile(ctx context.Context, session *mcp.ServerSession, params *m
 	if len(warnings) > 0 {
 		content = append(content, &mcp.TextContent{Text: "Warnings: " + strings.Join(warnings, "; ")})
 	}
+	content = append(content, op.finish()...)
 
 	return &mcp.CallToolResultFor[any]{
 		Content: content,
@@ -139,18 +160,725 @@ func RefactorFolder(ctx context.Context, session *mcp.ServerSession, params *mcp
 	if folderPath == "" {
 		folderPath = "doc"
 	}
+	folderPath, ok := docFolder(folderPath)
+	if !ok {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: "Failed to refactor folder: invalid path " + params.Arguments.FolderPath + ": must be inside doc"}},
+			IsError: true,
+		}, nil
+	}
+
+	// Links to the moved files are rewritten anywhere under doc/.
+	op := beginOperation("refactor_folder", params.Arguments, "doc", folderPath)
 
 	// This function will be implemented in refactor.go
-	err := RefactorFolderLogic(folderPath)
+	err := RefactorFolderLogic("doc", folderPath, RefactorOptions{
+		Strategy: params.Arguments.Strategy,
+		Clusters: params.Arguments.Clusters,
+		GroupKey: params.Arguments.GroupKey,
+		Indexes:  params.Arguments.Indexes,
+	})
+	journal := op.finish()
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: append([]mcp.Content{&mcp.TextContent{Text: "Failed to refactor folder: " + err.Error()}}, journal...),
+			IsError: true,
+		}, nil
+	}
+
+	return &mcp.CallToolResultFor[any]{
+		Content: append([]mcp.Content{&mcp.TextContent{Text: "Folder refactored successfully"}}, journal...),
+		IsError: false,
+	}, nil
+}
+
+func GenerateIndexes(ctx context.Context, session *mcp.ServerSession, params *mcp.CallToolParamsFor[GenerateIndexesParams]) (*mcp.CallToolResultFor[any], error) {
+	folderPath := params.Arguments.FolderPath
+	if folderPath == "" {
+		folderPath = "doc"
+	}
+	folderPath, ok := docFolder(folderPath)
+	if !ok {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: "Failed to generate indexes: invalid path " + params.Arguments.FolderPath + ": must be inside doc"}},
+			IsError: true,
+		}, nil
+	}
+
+	op := beginOperation("generate_indexes", params.Arguments, folderPath)
+
+	written, err := GenerateIndexesLogic("doc", folderPath)
+	journal := op.finish()
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: append([]mcp.Content{&mcp.TextContent{Text: "Failed to generate indexes: " + err.Error()}}, journal...),
+			IsError: true,
+		}, nil
+	}
+
+	return &mcp.CallToolResultFor[any]{
+		Content: append([]mcp.Content{&mcp.TextContent{Text: "Indexes generated: " + strings.Join(written, ", ")}}, journal...),
+		IsError: false,
+	}, nil
+}
+
+func MoveMarkdownFile(ctx context.Context, session *mcp.ServerSession, params *mcp.CallToolParamsFor[MoveMarkdownFileParams]) (*mcp.CallToolResultFor[any], error) {
+	scopes := append(linkedScopes(params.Arguments.From, true), docScopes(params.Arguments.To)...)
+	if config.AutoRefactor == AutoRefactorApply {
+		scopes = append(scopes, "doc")
+	}
+	op := beginOperation("move_markdown_file", params.Arguments, scopes...)
+
+	dest, updated, err := MoveMarkdownFileLogic("doc", params.Arguments.From, params.Arguments.To)
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: append([]mcp.Content{&mcp.TextContent{Text: "Failed to move file: " + err.Error()}}, op.finish()...),
+			IsError: true,
+		}, nil
+	}
+
+	refactored, dest := autoRefactorContent(filepath.Dir(dest), dest)
+	content := []mcp.Content{&mcp.TextContent{Text: "Moved " + params.Arguments.From + " to " + dest}}
+	if len(updated) > 0 {
+		content = append(content, &mcp.TextContent{Text: "Updated links in: " + strings.Join(updated, ", ")})
+	}
+	content = append(content, refactored...)
+	content = append(content, op.finish()...)
+
+	return &mcp.CallToolResultFor[any]{
+		Content: content,
+		IsError: false,
+	}, nil
+}
+
+func DeleteMarkdownFile(ctx context.Context, session *mcp.ServerSession, params *mcp.CallToolParamsFor[DeleteMarkdownFileParams]) (*mcp.CallToolResultFor[any], error) {
+	// The trash is journaled too so that an undo also drops the trashed copy.
+	op := beginOperation("delete_markdown_file", params.Arguments, append(linkedScopes(params.Arguments.Path, false), trashDir("doc"))...)
+
+	result, err := DeleteMarkdownFileLogic("doc", params.Arguments.Path, DeleteOptions{
+		Force:       params.Arguments.Force,
+		Replacement: params.Arguments.Replacement,
+		StripLinks:  params.Arguments.StripLinks,
+	})
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: append([]mcp.Content{&mcp.TextContent{Text: "Failed to delete file: " + err.Error()}}, op.finish()...),
+			IsError: true,
+		}, nil
+	}
+
+	content := []mcp.Content{&mcp.TextContent{Text: "Moved " + params.Arguments.Path + " to trash: " + result.Trashed}}
+	if len(result.Updated) > 0 {
+		content = append(content, &mcp.TextContent{Text: "Updated links in: " + strings.Join(result.Updated, ", ")})
+	} else if len(result.Referrers) > 0 {
+		content = append(content, &mcp.TextContent{Text: "Warnings: links are now broken in: " + strings.Join(result.Referrers, ", ")})
+	}
+	content = append(content, op.finish()...)
+
+	return &mcp.CallToolResultFor[any]{
+		Content: content,
+		IsError: false,
+	}, nil
+}
+
+func EditSection(ctx context.Context, session *mcp.ServerSession, params *mcp.CallToolParamsFor[EditSectionParams]) (*mcp.CallToolResultFor[any], error) {
+	op := beginOperation("edit_section", params.Arguments, linkedScopes(params.Arguments.Path, false)...)
+
+	result, err := EditSectionLogic("doc", params.Arguments.Path, params.Arguments.Heading, params.Arguments.NewHeading, params.Arguments.Content)
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: append([]mcp.Content{&mcp.TextContent{Text: "Failed to edit section: " + err.Error()}}, op.finish()...),
+			IsError: true,
+		}, nil
+	}
+
+	content := []mcp.Content{&mcp.TextContent{Text: "Section edited successfully: " + params.Arguments.Path + "#" + result.Anchor}}
+	if result.OldAnchor != "" && len(result.Updated) > 0 {
+		content = append(content, &mcp.TextContent{Text: fmt.Sprintf("Updated links to #%s in: %s", result.OldAnchor, strings.Join(result.Updated, ", "))})
+	}
+	if err := autoTOCFile(filepath.Join("doc", params.Arguments.Path)); err != nil {
+		content = append(content, &mcp.TextContent{Text: "Warnings: table of contents not updated: " + err.Error()})
+	}
+	content = append(content, op.finish()...)
+
+	return &mcp.CallToolResultFor[any]{
+		Content: content,
+		IsError: false,
+	}, nil
+}
+
+func ConvertWikiLinks(ctx context.Context, session *mcp.ServerSession, params *mcp.CallToolParamsFor[ConvertWikiLinksParams]) (*mcp.CallToolResultFor[any], error) {
+	op := beginOperation("convert_wiki_links", params.Arguments, docScopes(params.Arguments.Path)...)
+
+	converted, unresolved, err := ConvertWikiLinksLogic("doc", params.Arguments.Path)
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: append([]mcp.Content{&mcp.TextContent{Text: "Failed to convert wiki links: " + err.Error()}}, op.finish()...),
+			IsError: true,
+		}, nil
+	}
+
+	content := []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Converted wiki links in %d files", len(converted))}}
+	if len(converted) > 0 {
+		content = append(content, &mcp.TextContent{Text: "Updated: " + strings.Join(converted, ", ")})
+	}
+	if len(unresolved) > 0 {
+		content = append(content, &mcp.TextContent{Text: "Warnings: unresolved wiki links left as they are: " + strings.Join(unresolved, "; ")})
+	}
+	content = append(content, op.finish()...)
+
+	return &mcp.CallToolResultFor[any]{
+		Content: content,
+		IsError: false,
+	}, nil
+}
+
+func UpdateTOC(ctx context.Context, session *mcp.ServerSession, params *mcp.CallToolParamsFor[UpdateTOCParams]) (*mcp.CallToolResultFor[any], error) {
+	op := beginOperation("update_toc", params.Arguments, docScopes(params.Arguments.Path)...)
+
+	updated, err := UpdateTOCLogic("doc", params.Arguments.Path, params.Arguments.Depth)
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: append([]mcp.Content{&mcp.TextContent{Text: "Failed to update table of contents: " + err.Error()}}, op.finish()...),
+			IsError: true,
+		}, nil
+	}
+
+	content := []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Updated the table of contents of %d files", len(updated))}}
+	if len(updated) > 0 {
+		content = append(content, &mcp.TextContent{Text: "Updated: " + strings.Join(updated, ", ")})
+	}
+	content = append(content, op.finish()...)
+
+	return &mcp.CallToolResultFor[any]{
+		Content: content,
+		IsError: false,
+	}, nil
+}
+
+func StoreAsset(ctx context.Context, session *mcp.ServerSession, params *mcp.CallToolParamsFor[StoreAssetParams]) (*mcp.CallToolResultFor[any], error) {
+	asset := params.Arguments.Name
+	if !params.Arguments.NextToDoc {
+		asset = assetsFolderName + "/" + asset
+	}
+	op := beginOperation("store_asset", params.Arguments, docScopes(path.Join(path.Dir(params.Arguments.Doc), asset))...)
+
+	stored, err := StoreAssetLogic("doc", params.Arguments.Doc, params.Arguments.Name, params.Arguments.Data, StoreAssetOptions{
+		NextToDoc: params.Arguments.NextToDoc,
+		Overwrite: params.Arguments.Overwrite,
+	})
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: append([]mcp.Content{&mcp.TextContent{Text: "Failed to store asset: " + err.Error()}}, op.finish()...),
+			IsError: true,
+		}, nil
+	}
+
+	content := []mcp.Content{
+		&mcp.TextContent{Text: fmt.Sprintf("Stored asset %s (%d bytes)", stored.Path, stored.Size)},
+		&mcp.TextContent{Text: "Reference it from " + params.Arguments.Doc + " with: " + stored.Markdown},
+	}
+	content = append(content, op.finish()...)
+
+	return &mcp.CallToolResultFor[any]{
+		Content: content,
+		IsError: false,
+	}, nil
+}
+
+func GetAssetReport(ctx context.Context, session *mcp.ServerSession, params *mcp.CallToolParamsFor[AssetReportParams]) (*mcp.CallToolResultFor[any], error) {
+	report, err := AssetReportLogic("doc")
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: "Failed to build asset report: " + err.Error()}},
+			IsError: true,
+		}, nil
+	}
+	return jsonResult(report), nil
+}
+
+func CreateFromTemplate(ctx context.Context, session *mcp.ServerSession, params *mcp.CallToolParamsFor[CreateFromTemplateParams]) (*mcp.CallToolResultFor[any], error) {
+	filePath := filepath.Join("doc", filepath.FromSlash(params.Arguments.Path))
+	folder := filepath.Dir(filePath)
+	op := beginOperation("create_from_template", params.Arguments, operationScopes(filePath, folder)...)
+
+	markdown, err := CreateFromTemplateLogic("doc", params.Arguments.Template, params.Arguments.Path, params.Arguments.Variables)
+	if err == nil {
+		err = autoTOCFile(filePath)
+	}
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: append([]mcp.Content{&mcp.TextContent{Text: "Failed to create file from template: " + err.Error()}}, op.finish()...),
+			IsError: true,
+		}, nil
+	}
+	if source, err := os.ReadFile(filePath); err == nil {
+		markdown = string(source)
+	}
+
+	warnings := validateMarkdown(markdown)
+	warnings = append(warnings, validateLinkedAnchors(markdown, folder)...)
+	duplicates, _ := CheckDuplicatesLogic("doc", markdown, filePath, 0)
+
+	refactored, created := autoRefactorContent(folder, filePath)
+	content := []mcp.Content{&mcp.TextContent{Text: "File created successfully: " + created}}
+	if len(warnings) > 0 {
+		content = append(content, &mcp.TextContent{Text: "Warnings: " + strings.Join(warnings, "; ")})
+	}
+	if len(duplicates) > 0 {
+		content = append(content, &mcp.TextContent{Text: duplicateWarning("doc", duplicates)})
+	}
+	content = append(content, refactored...)
+	content = append(content, op.finish()...)
+
+	return &mcp.CallToolResultFor[any]{
+		Content: content,
+		IsError: false,
+	}, nil
+}
+
+func ListTemplates(ctx context.Context, session *mcp.ServerSession, params *mcp.CallToolParamsFor[ListTemplatesParams]) (*mcp.CallToolResultFor[any], error) {
+	templates, err := ListTemplatesLogic("doc")
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: "Failed to list templates: " + err.Error()}},
+			IsError: true,
+		}, nil
+	}
+	return jsonResult(templates), nil
+}
+
+func DocumentFeaturePrompt(ctx context.Context, session *mcp.ServerSession, params *mcp.GetPromptParams) (*mcp.GetPromptResult, error) {
+	return DocumentFeaturePromptLogic("doc", params.Arguments["feature"], params.Arguments["folder"])
+}
+
+func SummarizeFolderPrompt(ctx context.Context, session *mcp.ServerSession, params *mcp.GetPromptParams) (*mcp.GetPromptResult, error) {
+	return SummarizeFolderPromptLogic("doc", params.Arguments["folder"])
+}
+
+func FixValidationWarningsPrompt(ctx context.Context, session *mcp.ServerSession, params *mcp.GetPromptParams) (*mcp.GetPromptResult, error) {
+	return FixValidationWarningsPromptLogic("doc", params.Arguments["path"])
+}
+
+func WriteADRPrompt(ctx context.Context, session *mcp.ServerSession, params *mcp.GetPromptParams) (*mcp.GetPromptResult, error) {
+	return WriteADRPromptLogic("doc", params.Arguments["title"], params.Arguments["context"])
+}
+
+// autoRefactorContent applies the configured auto refactor policy to folder
+// and describes the outcome, if any, for a tool result. It also returns
+// where file, just written to folder, ended up: its new path, in the same
+// absolute or relative form, when the refactor moved it.
+// Folders outside doc/ are never refactored.
+func autoRefactorContent(folder, file string) ([]mcp.Content, string) {
+	target, ok := docFolder(folder)
+	if !ok {
+		return nil, file
+	}
+	suggestion, err := CheckFolderLimitLogic("doc", target, config.AutoRefactor, config.Refactor)
+	if err != nil {
+		return []mcp.Content{&mcp.TextContent{Text: "Auto refactor failed: " + err.Error()}}, file
+	}
+	if suggestion == nil {
+		return nil, file
+	}
+
+	plan, _ := json.MarshalIndent(suggestion, "", "  ")
+	if !suggestion.Applied {
+		return []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Folder %s has %d items (limit %d). Suggested refactor: %s", folder, suggestion.Items, suggestion.Limit, plan)}}, file
+	}
+
+	content := []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Folder %s had %d items (limit %d) and was refactored: %s", folder, suggestion.Items, suggestion.Limit, plan)}}
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return content, file
+	}
+	moved, ok := suggestion.Moved[abs]
+	if !ok {
+		return content, file
+	}
+	if !filepath.IsAbs(file) {
+		if cwd, err := os.Getwd(); err == nil {
+			if rel, err := filepath.Rel(cwd, moved); err == nil {
+				moved = rel
+			}
+		}
+	}
+	return content, moved
+}
+
+func UndoLastOperation(ctx context.Context, session *mcp.ServerSession, params *mcp.CallToolParamsFor[UndoLastOperationParams]) (*mcp.CallToolResultFor[any], error) {
+	op, err := UndoLastOperationLogic("doc", params.Arguments.Force)
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: "Failed to undo: " + err.Error()}},
+			IsError: true,
+		}, nil
+	}
+
+	paths := []string{}
+	for _, change := range op.Files {
+		paths = append(paths, change.Path)
+	}
+
+	notifyChanged(op)
+
+	content := []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Undid operation %d (%s), restored: %s", op.ID, op.Tool, strings.Join(paths, ", "))}}
+	content = append(content, commitContent(&Operation{
+		Tool:  "undo_last_operation",
+		Args:  map[string]any{"operation": op.ID, "tool": op.Tool},
+		Files: op.Files,
+	})...)
+
+	return &mcp.CallToolResultFor[any]{
+		Content: content,
+		IsError: false,
+	}, nil
+}
+
+func ListOperations(ctx context.Context, session *mcp.ServerSession, params *mcp.CallToolParamsFor[ListOperationsParams]) (*mcp.CallToolResultFor[any], error) {
+	ops, err := ListOperationsLogic("doc")
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: "Failed to list operations: " + err.Error()}},
+			IsError: true,
+		}, nil
+	}
+
+	if params.Arguments.Limit > 0 && len(ops) > params.Arguments.Limit {
+		ops = ops[len(ops)-params.Arguments.Limit:]
+	}
+	return jsonResult(ops), nil
+}
+
+func DocHistory(ctx context.Context, session *mcp.ServerSession, params *mcp.CallToolParamsFor[DocHistoryParams]) (*mcp.CallToolResultFor[any], error) {
+	commits, err := DocHistoryLogic("doc", params.Arguments.Path, params.Arguments.Limit)
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: "Failed to read history: " + err.Error()}},
+			IsError: true,
+		}, nil
+	}
+	return jsonResult(commits), nil
+}
+
+func DocDiff(ctx context.Context, session *mcp.ServerSession, params *mcp.CallToolParamsFor[DocDiffParams]) (*mcp.CallToolResultFor[any], error) {
+	hunks, err := DocDiffLogic("doc", params.Arguments.Path, params.Arguments.From, params.Arguments.To)
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: "Failed to diff document: " + err.Error()}},
+			IsError: true,
+		}, nil
+	}
+	return jsonResult(hunks), nil
+}
+
+func DocBlame(ctx context.Context, session *mcp.ServerSession, params *mcp.CallToolParamsFor[DocBlameParams]) (*mcp.CallToolResultFor[any], error) {
+	lines, err := DocBlameLogic("doc", params.Arguments.Path)
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: "Failed to blame document: " + err.Error()}},
+			IsError: true,
+		}, nil
+	}
+	return jsonResult(lines), nil
+}
+
+func SearchDocs(ctx context.Context, session *mcp.ServerSession, params *mcp.CallToolParamsFor[SearchDocsParams]) (*mcp.CallToolResultFor[any], error) {
+	results, err := SearchDocsLogic("doc", params.Arguments.Query, params.Arguments.Limit)
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: "Failed to search docs: " + err.Error()}},
+			IsError: true,
+		}, nil
+	}
+	return jsonResult(results), nil
+}
+
+func GetBacklinks(ctx context.Context, session *mcp.ServerSession, params *mcp.CallToolParamsFor[GetBacklinksParams]) (*mcp.CallToolResultFor[any], error) {
+	links, err := GetBacklinksLogic("doc", params.Arguments.Path)
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: "Failed to get backlinks: " + err.Error()}},
+			IsError: true,
+		}, nil
+	}
+	return jsonResult(links), nil
+}
+
+func GetOutgoingLinks(ctx context.Context, session *mcp.ServerSession, params *mcp.CallToolParamsFor[GetOutgoingLinksParams]) (*mcp.CallToolResultFor[any], error) {
+	links, err := GetOutgoingLinksLogic("doc", params.Arguments.Path)
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: "Failed to get outgoing links: " + err.Error()}},
+			IsError: true,
+		}, nil
+	}
+	return jsonResult(links), nil
+}
+
+func GetNeighbors(ctx context.Context, session *mcp.ServerSession, params *mcp.CallToolParamsFor[GetNeighborsParams]) (*mcp.CallToolResultFor[any], error) {
+	neighborhood, err := GetNeighborsLogic("doc", params.Arguments.Path, params.Arguments.Depth)
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: "Failed to get neighbors: " + err.Error()}},
+			IsError: true,
+		}, nil
+	}
+	return jsonResult(neighborhood), nil
+}
+
+func SuggestLinks(ctx context.Context, session *mcp.ServerSession, params *mcp.CallToolParamsFor[SuggestLinksParams]) (*mcp.CallToolResultFor[any], error) {
+	suggestions, err := SuggestLinksLogic("doc", params.Arguments.Path, params.Arguments.Content, params.Arguments.Limit)
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: "Failed to suggest links: " + err.Error()}},
+			IsError: true,
+		}, nil
+	}
+	return jsonResult(suggestions), nil
+}
+
+func GetLinkReport(ctx context.Context, session *mcp.ServerSession, params *mcp.CallToolParamsFor[LinkReportParams]) (*mcp.CallToolResultFor[any], error) {
+	report, err := LinkReportLogic("doc")
 	if err != nil {
 		return &mcp.CallToolResultFor[any]{
-			Content: []mcp.Content{&mcp.TextContent{Text: "Failed to refactor folder: " + err.Error()}},
+			Content: []mcp.Content{&mcp.TextContent{Text: "Failed to build link report: " + err.Error()}},
 			IsError: true,
 		}, nil
 	}
+	return jsonResult(report), nil
+}
 
+func ExportGraph(ctx context.Context, session *mcp.ServerSession, params *mcp.CallToolParamsFor[ExportGraphParams]) (*mcp.CallToolResultFor[any], error) {
+	graph, err := ExportGraphLogic("doc", ExportOptions{
+		Format:  params.Arguments.Format,
+		Cluster: params.Arguments.Cluster,
+		Path:    params.Arguments.Path,
+		Tag:     params.Arguments.Tag,
+	})
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: "Failed to export graph: " + err.Error()}},
+			IsError: true,
+		}, nil
+	}
 	return &mcp.CallToolResultFor[any]{
-		Content: []mcp.Content{&mcp.TextContent{Text: "Folder refactored successfully"}},
+		Content: []mcp.Content{&mcp.TextContent{Text: graph}},
 		IsError: false,
 	}, nil
-} 
\ No newline at end of file
+}
+
+func FindDuplicates(ctx context.Context, session *mcp.ServerSession, params *mcp.CallToolParamsFor[FindDuplicatesParams]) (*mcp.CallToolResultFor[any], error) {
+	if params.Arguments.Content != "" {
+		matches, err := CheckDuplicatesLogic("doc", params.Arguments.Content, "", params.Arguments.Threshold)
+		if err != nil {
+			return &mcp.CallToolResultFor[any]{
+				Content: []mcp.Content{&mcp.TextContent{Text: "Failed to find duplicates: " + err.Error()}},
+				IsError: true,
+			}, nil
+		}
+		return jsonResult(matches), nil
+	}
+
+	pairs, err := FindDuplicatesLogic("doc", params.Arguments.Path, params.Arguments.Threshold)
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: "Failed to find duplicates: " + err.Error()}},
+			IsError: true,
+		}, nil
+	}
+	return jsonResult(pairs), nil
+}
+
+func SemanticSearchDocs(ctx context.Context, session *mcp.ServerSession, params *mcp.CallToolParamsFor[SemanticSearchDocsParams]) (*mcp.CallToolResultFor[any], error) {
+	results, err := SemanticSearchDocsLogic("doc", params.Arguments.Query, params.Arguments.Limit)
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: "Failed to search docs: " + err.Error()}},
+			IsError: true,
+		}, nil
+	}
+	return jsonResult(results), nil
+}
+
+func GetIndexStats(ctx context.Context, session *mcp.ServerSession, params *mcp.CallToolParamsFor[IndexStatsParams]) (*mcp.CallToolResultFor[any], error) {
+	stats, err := IndexStatsLogic("doc")
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: "Failed to read index stats: " + err.Error()}},
+			IsError: true,
+		}, nil
+	}
+	return jsonResult(stats), nil
+}
+
+func RebuildIndex(ctx context.Context, session *mcp.ServerSession, params *mcp.CallToolParamsFor[RebuildIndexParams]) (*mcp.CallToolResultFor[any], error) {
+	stats, err := RebuildIndexLogic("doc")
+	if err != nil {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{&mcp.TextContent{Text: "Failed to rebuild index: " + err.Error()}},
+			IsError: true,
+		}, nil
+	}
+	return jsonResult(stats), nil
+}
+
+// jsonResult returns v as indented JSON text.
+func jsonResult(v any) *mcp.CallToolResultFor[any] {
+	data, _ := json.MarshalIndent(v, "", "  ")
+	return &mcp.CallToolResultFor[any]{
+		Content: []mcp.Content{&mcp.TextContent{Text: string(data)}},
+		IsError: false,
+	}
+}
+
+// trackedOperation journals a mutating tool call. Journal failures never
+// block the tool; they are reported as warnings in its result.
+type trackedOperation struct {
+	tracker *OperationTracker
+	scopes  []string
+	err     error
+}
+
+func beginOperation(tool string, args any, scopes ...string) *trackedOperation {
+	tracker, err := BeginOperation("doc", tool, args, scopes...)
+	return &trackedOperation{tracker: tracker, scopes: scopes, err: err}
+}
+
+func (o *trackedOperation) finish() []mcp.Content {
+	var recorded *Operation
+	if o.err == nil {
+		recorded, o.err = o.tracker.Finish()
+	}
+	if o.err != nil {
+		// Without a record of what changed, refresh everything in scope.
+		notifyPaths(o.scopes)
+		return []mcp.Content{&mcp.TextContent{Text: "Warnings: operation not recorded for undo: " + o.err.Error()}}
+	}
+	notifyChanged(recorded)
+	return commitContent(recorded)
+}
+
+// notifyChanged keeps the in-memory indexes in step with the files an
+// operation touched.
+func notifyChanged(op *Operation) {
+	if op == nil {
+		return
+	}
+	paths := []string{}
+	for _, change := range op.Files {
+		paths = append(paths, change.Path)
+	}
+	notifyPaths(paths)
+}
+
+// notifyPaths keeps the in-memory indexes in step with changed files or
+// folders under doc/.
+func notifyPaths(paths []string) {
+	notifySearchIndex("doc", paths)
+	notifyLinkGraph("doc", paths)
+}
+
+// commitContent commits the files touched by op when git auto-commit is
+// enabled and describes the outcome for a tool result.
+func commitContent(op *Operation) []mcp.Content {
+	if !config.Git.AutoCommit || op == nil {
+		return nil
+	}
+
+	hash, ignored, err := CommitOperationLogic(op, config.Git)
+	content := []mcp.Content{}
+	if len(ignored) > 0 {
+		content = append(content, &mcp.TextContent{Text: "Warnings: not committed, ignored by git: " + strings.Join(ignored, ", ")})
+	}
+	if err != nil {
+		return append(content, &mcp.TextContent{Text: "Warnings: git commit failed: " + err.Error()})
+	}
+	if hash != "" {
+		content = append(content, &mcp.TextContent{Text: "Committed to git: " + hash})
+	}
+	return content
+}
+
+// operationScopes returns what a create of file may touch: the file
+// itself, plus its folder and the documents linking into it when the auto
+// refactor may rearrange it.
+func operationScopes(file, folder string) []string {
+	if target, ok := docFolder(folder); ok && config.AutoRefactor == AutoRefactorApply {
+		return []string{file, target, "doc"}
+	}
+	return []string{file}
+}
+
+// docFolder returns folder, relative to the working directory, as a path
+// under doc/, and false when it lies outside doc/.
+func docFolder(folder string) (string, bool) {
+	rel, err := filepath.Rel("doc", filepath.Clean(folder))
+	if err != nil {
+		return "", false
+	}
+	target, err := resolveInRoot("doc", rel)
+	if err != nil {
+		return "", false
+	}
+	return target, true
+}
+
+// docScopes returns the journal scope for path, a file or folder relative
+// to doc/: the path itself, all of doc/ when it is empty, and nothing when
+// it lies outside doc/, which the tool rejects anyway.
+func docScopes(path string) []string {
+	if path == "" {
+		return []string{"doc"}
+	}
+	target, err := resolveInRoot("doc", path)
+	if err != nil {
+		return nil
+	}
+	return []string{target}
+}
+
+// linkedScopes returns what rewriting the links to path, a file or folder
+// relative to doc/, may touch: path itself and the documents linking to
+// the documents in it, found in the link graph. With assets, the images and
+// files its documents reference, which moves carry along, are included.
+// All of doc/ is returned when the graph cannot be built.
+func linkedScopes(path string, assets bool) []string {
+	scopes := docScopes(path)
+	if len(scopes) == 0 {
+		return nil
+	}
+	target := scopes[0]
+	files := []string{target}
+	if info, err := os.Stat(target); err == nil && info.IsDir() {
+		var err error
+		if files, err = markdownFilesIn(target); err != nil {
+			return []string{"doc"}
+		}
+	}
+
+	g, err := linkGraphFor("doc")
+	if err != nil {
+		return []string{"doc"}
+	}
+	for _, file := range files {
+		if refs, err := g.Backlinks(rootRelPath("doc", file)); err == nil {
+			for _, ref := range refs {
+				scopes = append(scopes, filepath.Join("doc", filepath.FromSlash(ref.Path)))
+			}
+		}
+		if !assets {
+			continue
+		}
+		source, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		for _, ref := range assetRefs(newMarkdownParser().Parser().Parse(text.NewReader(source))) {
+			if isLocalDest(ref.dest) {
+				scopes = append(scopes, filepath.Join(filepath.Dir(file), filepath.FromSlash(ref.path)))
+			}
+		}
+	}
+	return scopes
+}
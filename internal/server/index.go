@@ -0,0 +1,182 @@
+package server
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+const (
+	indexFileName    = "index.md"
+	indexStartMarker = "<!-- doc-mcp:index:start -->"
+	indexEndMarker   = "<!-- doc-mcp:index:end -->"
+	maxSummaryLength = 160
+)
+
+// GenerateIndexesLogic writes an index.md into folderPath and every folder
+// below it, listing subfolders and documents with their titles and summaries.
+// Only the block between the index markers is regenerated; anything written
+// by hand around it is kept. The templates folder of root, the doc root
+// containing folderPath, is skipped. It returns the paths of the index files
+// written.
+func GenerateIndexesLogic(root, folderPath string) ([]string, error) {
+	written := []string{}
+	err := filepath.WalkDir(folderPath, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if path != folderPath && (strings.HasPrefix(d.Name(), ".") || isTemplatesFolder(root, path)) {
+			return filepath.SkipDir
+		}
+
+		indexPath, err := writeIndex(root, path)
+		if err != nil {
+			return err
+		}
+		written = append(written, indexPath)
+		return nil
+	})
+	if err != nil {
+		return written, fmt.Errorf("failed to generate indexes in %s: %w", folderPath, err)
+	}
+
+	return written, nil
+}
+
+func writeIndex(root, folderPath string) (string, error) {
+	entries, err := folderEntries(root, folderPath)
+	if err != nil {
+		return "", err
+	}
+
+	folders := []string{}
+	documents := []string{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			folders = append(folders, fmt.Sprintf("- [%s/](%s)", escapeLinkText(entry.Name()), escapeLinkDestination(entry.Name()+"/"+indexFileName)))
+			continue
+		}
+		if !strings.HasSuffix(entry.Name(), ".md") || entry.Name() == indexFileName {
+			continue
+		}
+
+		source, err := os.ReadFile(filepath.Join(folderPath, entry.Name()))
+		if err != nil {
+			return "", fmt.Errorf("failed to read file %s: %w", entry.Name(), err)
+		}
+
+		line := fmt.Sprintf("- [%s](%s)", docTitle(source, entry.Name()), escapeLinkDestination(entry.Name()))
+		if summary := docSummary(source); summary != "" {
+			line += " - " + summary
+		}
+		documents = append(documents, line)
+	}
+	sort.Strings(folders)
+	sort.Strings(documents)
+
+	var block strings.Builder
+	block.WriteString(indexStartMarker + "\n")
+	block.WriteString("## Contents\n\n")
+	for _, line := range append(folders, documents...) {
+		block.WriteString(line + "\n")
+	}
+	block.WriteString(indexEndMarker + "\n")
+
+	indexPath := filepath.Join(folderPath, indexFileName)
+	existing, err := os.ReadFile(indexPath)
+	if err != nil && !os.IsNotExist(err) {
+		return "", fmt.Errorf("failed to read index %s: %w", indexPath, err)
+	}
+
+	var content string
+	current := string(existing)
+	start := strings.Index(current, indexStartMarker)
+	end := strings.Index(current, indexEndMarker)
+	switch {
+	case start >= 0 && end > start:
+		after := strings.TrimPrefix(current[end+len(indexEndMarker):], "\n")
+		content = current[:start] + block.String() + after
+	case len(existing) > 0:
+		content = strings.TrimRight(current, "\n") + "\n\n" + block.String()
+	default:
+		content = "# " + filepath.Base(filepath.Clean(folderPath)) + "\n\n" + block.String()
+	}
+
+	if err := os.WriteFile(indexPath, []byte(content), 0644); err != nil {
+		return "", fmt.Errorf("failed to write index %s: %w", indexPath, err)
+	}
+
+	return indexPath, nil
+}
+
+// docTitle returns the frontmatter title of a document, else its first
+// heading, else fallback without the .md extension.
+func docTitle(source []byte, fallback string) string {
+	meta, body := splitFrontmatter(source)
+	if title := frontmatterValue(meta, "title"); title != "" {
+		return title
+	}
+
+	doc := goldmark.New().Parser().Parse(text.NewReader(body))
+	title := ""
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if heading, ok := n.(*ast.Heading); ok && entering {
+			title = plainText(heading, body)
+			return ast.WalkStop, nil
+		}
+		return ast.WalkContinue, nil
+	})
+	if title != "" {
+		return title
+	}
+
+	return strings.TrimSuffix(filepath.Base(fallback), ".md")
+}
+
+// docSummary returns the first paragraph of a document on a single line,
+// shortened to maxSummaryLength characters.
+func docSummary(source []byte) string {
+	_, body := splitFrontmatter(source)
+	doc := goldmark.New().Parser().Parse(text.NewReader(body))
+
+	summary := ""
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if paragraph, ok := n.(*ast.Paragraph); ok && entering {
+			summary = plainText(paragraph, body)
+			if summary != "" {
+				return ast.WalkStop, nil
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+
+	if runes := []rune(summary); len(runes) > maxSummaryLength {
+		summary = strings.TrimSpace(string(runes[:maxSummaryLength-3])) + "..."
+	}
+	return summary
+}
+
+// plainText returns the text inside n on a single line, with line breaks
+// turned into spaces.
+func plainText(n ast.Node, source []byte) string {
+	var b strings.Builder
+	ast.Walk(n, func(child ast.Node, entering bool) (ast.WalkStatus, error) {
+		if t, ok := child.(*ast.Text); ok && entering {
+			b.Write(t.Segment.Value(source))
+			if t.SoftLineBreak() || t.HardLineBreak() {
+				b.WriteByte(' ')
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	return strings.Join(strings.Fields(b.String()), " ")
+}
@@ -0,0 +1,392 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Operation is one recorded call of a mutating tool.
+type Operation struct {
+	ID    int          `json:"id"`
+	Tool  string       `json:"tool"`
+	Args  any          `json:"args,omitempty"`
+	Time  time.Time    `json:"time"`
+	Files []FileChange `json:"files"`
+}
+
+// FileChange is the state of one file before and after an operation, as
+// SHA-256 hashes of its content. An empty hash means the file did not exist.
+// The content behind every hash is kept in the journal's object store.
+type FileChange struct {
+	Path   string `json:"path"`
+	Before string `json:"before,omitempty"`
+	After  string `json:"after,omitempty"`
+}
+
+// OperationTracker captures the files in scope before a mutation so that
+// Finish can record what the mutation changed.
+type OperationTracker struct {
+	root   string
+	tool   string
+	args   any
+	scopes []string
+	before map[string][]byte
+}
+
+// maxJournalOperations is how many operations the journal keeps. Older ones
+// are dropped, along with the snapshots only they used, and can no longer
+// be undone.
+const maxJournalOperations = 100
+
+var journalMu sync.Mutex
+
+func journalDir(root string) string {
+	return filepath.Join(root, stateDir, "journal")
+}
+
+// BeginOperation snapshots every file under scopes, which may be files or
+// folders, ahead of a mutating tool call. The operation is journaled under
+// root/.doc-mcp/journal once Finish is called.
+func BeginOperation(root, tool string, args any, scopes ...string) (*OperationTracker, error) {
+	scopes, err := normalizeScopes(scopes)
+	if err != nil {
+		return nil, err
+	}
+	before, err := snapshotFiles(scopes)
+	if err != nil {
+		return nil, err
+	}
+	return &OperationTracker{root: root, tool: tool, args: args, scopes: scopes, before: before}, nil
+}
+
+// Finish compares the files in scope with the snapshot taken by
+// BeginOperation and records the differences. It returns nil when nothing
+// changed.
+func (t *OperationTracker) Finish() (*Operation, error) {
+	after, err := snapshotFiles(t.scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make(map[string]bool)
+	for path := range t.before {
+		paths[path] = true
+	}
+	for path := range after {
+		paths[path] = true
+	}
+
+	op := &Operation{Tool: t.tool, Args: t.args, Time: time.Now().UTC(), Files: []FileChange{}}
+	for path := range paths {
+		change := FileChange{Path: path}
+		if content, ok := t.before[path]; ok {
+			if change.Before, err = storeObject(t.root, content); err != nil {
+				return nil, err
+			}
+		}
+		if content, ok := after[path]; ok {
+			if change.After, err = storeObject(t.root, content); err != nil {
+				return nil, err
+			}
+		}
+		if change.Before != change.After {
+			op.Files = append(op.Files, change)
+		}
+	}
+	if len(op.Files) == 0 {
+		return nil, nil
+	}
+	sort.Slice(op.Files, func(i, j int) bool { return op.Files[i].Path < op.Files[j].Path })
+
+	journalMu.Lock()
+	defer journalMu.Unlock()
+
+	ops, err := readJournal(t.root)
+	if err != nil {
+		return nil, err
+	}
+	op.ID = 1
+	if len(ops) > 0 {
+		op.ID = ops[len(ops)-1].ID + 1
+	}
+	ops = append(ops, *op)
+	pruned := len(ops) > maxJournalOperations
+	if pruned {
+		ops = ops[len(ops)-maxJournalOperations:]
+	}
+	if err := writeJournal(t.root, ops); err != nil {
+		return nil, err
+	}
+	if pruned {
+		if err := pruneObjects(t.root, ops); err != nil {
+			return nil, err
+		}
+	}
+
+	return op, nil
+}
+
+// normalizeScopes makes scopes absolute and drops duplicates and scopes
+// inside another one, so that no file is snapshotted twice. Scopes inside a
+// hidden folder of another one are kept, since its walk skips them.
+func normalizeScopes(scopes []string) ([]string, error) {
+	abs := []string{}
+	for _, scope := range scopes {
+		path, err := filepath.Abs(scope)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve %s: %w", scope, err)
+		}
+		abs = append(abs, path)
+	}
+	sort.Strings(abs)
+
+	normalized := []string{}
+	for _, path := range abs {
+		if !coveredBy(path, normalized) {
+			normalized = append(normalized, path)
+		}
+	}
+	return normalized, nil
+}
+
+// coveredBy reports whether walking scopes already snapshots path.
+func coveredBy(path string, scopes []string) bool {
+	for _, scope := range scopes {
+		if path == scope {
+			return true
+		}
+		if strings.HasPrefix(path, scope+string(filepath.Separator)) && !hiddenPath(path[len(scope)+1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// hiddenPath reports whether any element of the relative path rel is hidden.
+func hiddenPath(rel string) bool {
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		if strings.HasPrefix(part, ".") {
+			return true
+		}
+	}
+	return false
+}
+
+// pruneObjects removes the snapshots no operation in ops refers to.
+func pruneObjects(root string, ops []Operation) error {
+	used := make(map[string]bool)
+	for _, op := range ops {
+		for _, change := range op.Files {
+			used[change.Before] = true
+			used[change.After] = true
+		}
+	}
+	dir := filepath.Join(journalDir(root), "objects")
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to read object store: %w", err)
+	}
+	for _, entry := range entries {
+		if !used[entry.Name()] {
+			if err := os.Remove(filepath.Join(dir, entry.Name())); err != nil {
+				return fmt.Errorf("failed to prune snapshot: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// ListOperationsLogic returns the journaled operations under root, oldest
+// first.
+func ListOperationsLogic(root string) ([]Operation, error) {
+	journalMu.Lock()
+	defer journalMu.Unlock()
+
+	return readJournal(root)
+}
+
+// UndoLastOperationLogic reverts the most recent journaled operation under
+// root and removes it from the journal. Files changed since the operation are
+// left alone and reported as an error unless force is set.
+func UndoLastOperationLogic(root string, force bool) (*Operation, error) {
+	journalMu.Lock()
+	defer journalMu.Unlock()
+
+	ops, err := readJournal(root)
+	if err != nil {
+		return nil, err
+	}
+	if len(ops) == 0 {
+		return nil, fmt.Errorf("no operations to undo")
+	}
+	op := ops[len(ops)-1]
+
+	if !force {
+		conflicts := []string{}
+		for _, change := range op.Files {
+			current, err := fileHash(change.Path)
+			if err != nil {
+				return nil, err
+			}
+			if current != change.After {
+				conflicts = append(conflicts, change.Path)
+			}
+		}
+		if len(conflicts) > 0 {
+			return nil, fmt.Errorf("files changed since operation %d (%s): %s; pass force to undo anyway", op.ID, op.Tool, strings.Join(conflicts, ", "))
+		}
+	}
+
+	for _, change := range op.Files {
+		if change.Before == "" {
+			if err := os.Remove(change.Path); err != nil && !os.IsNotExist(err) {
+				return nil, fmt.Errorf("failed to remove %s: %w", change.Path, err)
+			}
+			removeEmptyParents(filepath.Dir(change.Path), root)
+			continue
+		}
+
+		content, err := os.ReadFile(filepath.Join(journalDir(root), "objects", change.Before))
+		if err != nil {
+			return nil, fmt.Errorf("missing snapshot for %s: %w", change.Path, err)
+		}
+		if err := os.MkdirAll(filepath.Dir(change.Path), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create directory for %s: %w", change.Path, err)
+		}
+		if err := os.WriteFile(change.Path, content, 0644); err != nil {
+			return nil, fmt.Errorf("failed to restore %s: %w", change.Path, err)
+		}
+	}
+
+	if err := writeJournal(root, ops[:len(ops)-1]); err != nil {
+		return nil, err
+	}
+
+	return &op, nil
+}
+
+// snapshotFiles reads every file under the given files or folders, skipping
+// hidden folders. Missing scopes are treated as empty.
+func snapshotFiles(scopes []string) (map[string][]byte, error) {
+	files := make(map[string][]byte)
+	for _, scope := range scopes {
+		err := filepath.WalkDir(scope, func(path string, d os.DirEntry, err error) error {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if path != scope && strings.HasPrefix(d.Name(), ".") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			files[filepath.Clean(path)] = content
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to snapshot %s: %w", scope, err)
+		}
+	}
+	return files, nil
+}
+
+func hashContent(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+func fileHash(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return hashContent(content), nil
+}
+
+// storeObject saves content in the journal's content-addressed object store
+// and returns its hash.
+func storeObject(root string, content []byte) (string, error) {
+	hash := hashContent(content)
+	path := filepath.Join(journalDir(root), "objects", hash)
+	if _, err := os.Stat(path); err == nil {
+		return hash, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("failed to create object store: %w", err)
+	}
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		return "", fmt.Errorf("failed to store snapshot: %w", err)
+	}
+	return hash, nil
+}
+
+func readJournal(root string) ([]Operation, error) {
+	data, err := os.ReadFile(filepath.Join(journalDir(root), "operations.json"))
+	if os.IsNotExist(err) {
+		return []Operation{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read journal: %w", err)
+	}
+
+	ops := []Operation{}
+	if err := json.Unmarshal(data, &ops); err != nil {
+		return nil, fmt.Errorf("failed to parse journal: %w", err)
+	}
+	return ops, nil
+}
+
+func writeJournal(root string, ops []Operation) error {
+	if err := os.MkdirAll(journalDir(root), 0755); err != nil {
+		return fmt.Errorf("failed to create journal folder: %w", err)
+	}
+	data, err := json.MarshalIndent(ops, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode journal: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(journalDir(root), "operations.json"), data, 0644); err != nil {
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	return nil
+}
+
+// removeEmptyParents removes dir and its parents while they are empty,
+// stopping at root. Nothing outside root is removed.
+func removeEmptyParents(dir, root string) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return
+	}
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return
+	}
+	for {
+		rel, err := filepath.Rel(root, dir)
+		if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return
+		}
+		if err := os.Remove(dir); err != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
@@ -0,0 +1,335 @@
+package server
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+var linkDefinitionRe = regexp.MustCompile(`^ {0,3}\[((?:[^\[\]\\]|\\.)+)\]:[ \t]*(?:<([^<>\n]*)>|(\S+))`)
+
+// linkSpan is where a link or image is written in a source, from its
+// opening bracket to its closing parenthesis or bracket. The text span lies
+// between the brackets. Inline links have the span of their destination;
+// reference links such as [text][docs] have the label of the definition
+// holding it instead.
+type linkSpan struct {
+	start, stop         int
+	textStart, textStop int
+	destStart, destStop int
+	label               string
+}
+
+// linkDefinition is a reference definition such as [docs]: guide.md, with
+// the span of its line and of its destination.
+type linkDefinition struct {
+	label               string
+	dest                string
+	start, stop         int
+	destStart, destStop int
+}
+
+// sourceLinks locates the links, images and reference definitions of a
+// parsed document in its source, so that they can be rewritten in place
+// rather than by rendering the document again, which would inline reference
+// links and drop their definitions.
+type sourceLinks struct {
+	source      []byte
+	spans       map[ast.Node]linkSpan
+	definitions map[string]linkDefinition
+}
+
+// parseLinks parses source with md and locates its links.
+func parseLinks(md goldmark.Markdown, source []byte) (ast.Node, *sourceLinks) {
+	ctx := parser.NewContext()
+	doc := md.Parser().Parse(text.NewReader(source), parser.WithContext(ctx))
+
+	links := &sourceLinks{
+		source:      source,
+		spans:       make(map[ast.Node]linkSpan),
+		definitions: make(map[string]linkDefinition),
+	}
+	links.findDefinitions(doc, ctx)
+
+	cursor := 0
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		if n.Type() == ast.TypeBlock && n.Lines().Len() > 0 {
+			cursor = n.Lines().At(0).Start
+		}
+		switch n.(type) {
+		case *ast.Link, *ast.Image:
+			if span, ok := links.locate(n, cursor); ok {
+				cursor = span.textStart
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	return doc, links
+}
+
+// findDefinitions finds the reference definitions goldmark took out of the
+// document: lines that no block covers and that define a label it knows.
+// As in CommonMark, the first definition of a label wins.
+func (l *sourceLinks) findDefinitions(doc ast.Node, ctx parser.Context) {
+	covered := make(map[int]bool)
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if entering && n.Type() == ast.TypeBlock {
+			lines := n.Lines()
+			for i := 0; i < lines.Len(); i++ {
+				covered[bytes.LastIndexByte(l.source[:lines.At(i).Start], '\n')+1] = true
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+
+	for start := 0; start < len(l.source); {
+		stop := len(l.source)
+		if i := bytes.IndexByte(l.source[start:], '\n'); i >= 0 {
+			stop = start + i + 1
+		}
+		line := l.source[start:stop]
+		m := linkDefinitionRe.FindSubmatchIndex(line)
+		if covered[start] || m == nil {
+			start = stop
+			continue
+		}
+
+		label := util.ToLinkReference(line[m[2]:m[3]])
+		destStart, destStop := m[6], m[7]
+		if m[4] >= 0 {
+			destStart, destStop = m[4], m[5]
+		}
+		dest := string(line[destStart:destStop])
+		ref, ok := ctx.Reference(label)
+		if _, seen := l.definitions[label]; ok && !seen && string(ref.Destination()) == dest {
+			l.definitions[label] = linkDefinition{
+				label:     label,
+				dest:      dest,
+				start:     start,
+				stop:      stop,
+				destStart: start + destStart,
+				destStop:  start + destStop,
+			}
+		}
+		start = stop
+	}
+}
+
+// locate finds the span of a link or image. Its text is found from the
+// positions of the text inside it; a link without text is looked for from
+// offset from on. Links whose written destination does not match the
+// parsed one are not located.
+func (l *sourceLinks) locate(n ast.Node, from int) (linkSpan, bool) {
+	if span, ok := l.spans[n]; ok {
+		return span, true
+	}
+	source := l.source
+
+	var dest []byte
+	switch node := n.(type) {
+	case *ast.Link:
+		dest = node.Destination
+	case *ast.Image:
+		dest = node.Destination
+	}
+
+	open := -1
+	textStart, textStop, ok := l.childrenRange(n, from)
+	if ok {
+		open = bytes.LastIndexByte(source[:textStart], '[')
+	} else if i := bytes.Index(source[from:], []byte("[]")); i >= 0 {
+		open = from + i
+		textStop = open + 1
+	}
+	if open < 0 {
+		return linkSpan{}, false
+	}
+	close := bytes.IndexByte(source[textStop:], ']')
+	if close < 0 {
+		return linkSpan{}, false
+	}
+	close += textStop
+
+	span := linkSpan{start: open, textStart: open + 1, textStop: close}
+	if _, image := n.(*ast.Image); image {
+		if open == 0 || source[open-1] != '!' {
+			return linkSpan{}, false
+		}
+		span.start--
+	}
+
+	if close+1 < len(source) && source[close+1] == '(' {
+		destStart, destStop, stop, ok := inlineDestination(source, close+2)
+		if !ok || !bytes.Equal(source[destStart:destStop], dest) {
+			return linkSpan{}, false
+		}
+		span.destStart, span.destStop, span.stop = destStart, destStop, stop
+	} else {
+		label := source[open+1 : close]
+		stop := close + 1
+		if stop < len(source) && source[stop] == '[' {
+			end := bytes.IndexByte(source[stop:], ']')
+			if end < 0 {
+				return linkSpan{}, false
+			}
+			if end > 1 {
+				label = source[stop+1 : stop+end]
+			}
+			stop += end + 1
+		}
+		def, ok := l.definitions[util.ToLinkReference(label)]
+		if !ok || def.dest != string(dest) {
+			return linkSpan{}, false
+		}
+		span.label, span.stop = def.label, stop
+	}
+
+	l.spans[n] = span
+	return span, true
+}
+
+// childrenRange returns the span of the source covered by the children of
+// n, including nested links and images.
+func (l *sourceLinks) childrenRange(n ast.Node, from int) (int, int, bool) {
+	start, stop, found := 0, 0, false
+	for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+		s, e, ok := l.rangeOf(c, from)
+		if !ok {
+			continue
+		}
+		if !found {
+			start, found = s, true
+		}
+		stop = e
+	}
+	return start, stop, found
+}
+
+func (l *sourceLinks) rangeOf(n ast.Node, from int) (int, int, bool) {
+	switch node := n.(type) {
+	case *ast.Text:
+		return node.Segment.Start, node.Segment.Stop, true
+	case *ast.RawHTML:
+		if node.Segments.Len() == 0 {
+			return 0, 0, false
+		}
+		return node.Segments.At(0).Start, node.Segments.At(node.Segments.Len() - 1).Stop, true
+	case *ast.Link, *ast.Image:
+		span, ok := l.locate(n, from)
+		return span.start, span.stop, ok
+	}
+	return l.childrenRange(n, from)
+}
+
+// inlineDestination parses the destination and optional title of an inline
+// link starting at i, just past its opening parenthesis. It returns the
+// span of the destination, without angle brackets, and the offset past the
+// closing parenthesis.
+func inlineDestination(source []byte, i int) (int, int, int, bool) {
+	i = skipLinkSpace(source, i)
+	start, stop := i, i
+	if i < len(source) && source[i] == '<' {
+		j := i + 1
+		for j < len(source) && source[j] != '>' && source[j] != '\n' {
+			if source[j] == '\\' {
+				j++
+			}
+			j++
+		}
+		if j >= len(source) || source[j] != '>' {
+			return 0, 0, 0, false
+		}
+		start, stop, i = i+1, j, j+1
+	} else {
+		depth := 0
+		for ; i < len(source); i++ {
+			c := source[i]
+			if c == '\\' && i+1 < len(source) {
+				i++
+				continue
+			}
+			if c == '(' {
+				depth++
+			} else if c == ')' {
+				if depth == 0 {
+					break
+				}
+				depth--
+			} else if c == ' ' || c == '\t' || c == '\n' || c == '\r' {
+				break
+			}
+		}
+		stop = i
+	}
+
+	i = skipLinkSpace(source, i)
+	if i < len(source) && (source[i] == '"' || source[i] == '\'' || source[i] == '(') {
+		closer := source[i]
+		if closer == '(' {
+			closer = ')'
+		}
+		for i++; i < len(source) && source[i] != closer; i++ {
+			if source[i] == '\\' {
+				i++
+			}
+		}
+		i = skipLinkSpace(source, i+1)
+	}
+	if i >= len(source) || source[i] != ')' {
+		return 0, 0, 0, false
+	}
+	return start, stop, i + 1, true
+}
+
+func skipLinkSpace(source []byte, i int) int {
+	for i < len(source) && (source[i] == ' ' || source[i] == '\t' || source[i] == '\n' || source[i] == '\r') {
+		i++
+	}
+	return i
+}
+
+// retarget calls fn with the destination of every inline link and image and
+// of every reference definition, and returns the edits that replace the
+// destinations fn changes. Reference links are rewritten once, through
+// their definition.
+func (l *sourceLinks) retarget(fn func(dest string) (string, bool)) []sourceEdit {
+	edits := []sourceEdit{}
+	replace := func(start, stop int) {
+		dest := string(l.source[start:stop])
+		newDest, ok := fn(dest)
+		if !ok || newDest == dest {
+			return
+		}
+		// Only destinations in angle brackets may contain spaces.
+		if strings.ContainsAny(newDest, " \t") && (start == 0 || l.source[start-1] != '<') {
+			newDest = "<" + newDest + ">"
+		}
+		edits = append(edits, sourceEdit{start: start, stop: stop, text: newDest})
+	}
+	for _, span := range l.spans {
+		if span.label == "" {
+			replace(span.destStart, span.destStop)
+		}
+	}
+	for _, def := range l.definitions {
+		replace(def.destStart, def.destStop)
+	}
+	return edits
+}
+
+// endWithNewline makes sure rewritten markdown ends with a newline.
+func endWithNewline(source []byte) []byte {
+	if len(source) > 0 && source[len(source)-1] != '\n' {
+		return append(source, '\n')
+	}
+	return source
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// resolveInRoot joins a path relative to root and rejects paths that are
+// absolute or escape root.
+func resolveInRoot(root, rel string) (string, error) {
+	if rel == "" {
+		return "", fmt.Errorf("path is required")
+	}
+	cleaned := filepath.Clean(rel)
+	if filepath.IsAbs(rel) || cleaned == ".." || strings.HasPrefix(cleaned, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid path %s: must be relative and inside %s", rel, root)
+	}
+	return filepath.Join(root, cleaned), nil
+}
+
+// MoveMarkdownFileLogic moves a file or folder within root and rewrites the
+// relative links of every markdown file under root, inbound and outbound, so
+// they keep pointing at the same documents. from and to are relative to root;
+// when to is an existing folder the source is moved into it. It returns the
+// new path and the files whose links were rewritten.
+func MoveMarkdownFileLogic(root, from, to string) (string, []string, error) {
+	source, err := resolveInRoot(root, from)
+	if err != nil {
+		return "", nil, err
+	}
+	dest, err := resolveInRoot(root, to)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if _, err := os.Stat(source); err != nil {
+		return "", nil, fmt.Errorf("source %s not found: %w", from, err)
+	}
+	if info, err := os.Stat(dest); err == nil {
+		if !info.IsDir() {
+			return "", nil, fmt.Errorf("destination %s already exists", to)
+		}
+		dest = filepath.Join(dest, filepath.Base(source))
+		if _, err := os.Stat(dest); err == nil {
+			return "", nil, fmt.Errorf("destination %s already exists", dest)
+		}
+	}
+
+	absSource, _ := filepath.Abs(source)
+	absDest, _ := filepath.Abs(dest)
+	if strings.HasPrefix(absDest, absSource+string(filepath.Separator)) {
+		return "", nil, fmt.Errorf("cannot move %s into itself", from)
+	}
+
+	movedFiles := make(map[string]string)
+	if err := applyMoves([]RefactorMove{{From: source, To: dest}}, movedFiles); err != nil {
+		return "", nil, err
+	}
+
+	if err := carryAssets(root, movedFiles); err != nil {
+		return dest, nil, err
+	}
+
+	updated, err := updateLinksLogic(root, movedFiles)
+	if err != nil {
+		return dest, updated, fmt.Errorf("failed to update links: %w", err)
+	}
+
+	return dest, updated, nil
+}
@@ -0,0 +1,359 @@
+package server
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// maxPromptDocuments is how many documents a prompt embeds at most, so that
+// prompts on large folders stay within the client's context.
+const maxPromptDocuments = 10
+
+// adrFolders are the folders, under the doc root, looked for in turn to
+// find architecture decision records. The first is used when none exists.
+var adrFolders = []string{"adr", "adrs", "decisions"}
+
+var adrNumberRe = regexp.MustCompile(`^(\d+)-`)
+
+// defaultADRSlug names a new record whose title yields no slug, such as one
+// written only in punctuation.
+const defaultADRSlug = "decision"
+
+// DocumentFeaturePromptLogic builds the document_feature prompt: a request
+// to document feature in folder, relative to root, with the documents that
+// already mention it embedded so that it is not documented twice.
+func DocumentFeaturePromptLogic(root, feature, folder string) (*mcp.GetPromptResult, error) {
+	if strings.TrimSpace(feature) == "" {
+		return nil, fmt.Errorf("feature is required")
+	}
+	if folder == "" {
+		folder = "."
+	}
+	if _, err := resolveInRoot(root, folder); err != nil {
+		return nil, err
+	}
+
+	related, err := SearchDocsLogic(root, feature, 3)
+	if err != nil {
+		return nil, err
+	}
+	templates, err := ListTemplatesLogic(root)
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, t := range templates {
+		names = append(names, t.Name)
+	}
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "Document the feature %q in %s.\n\n", feature, promptFolder(folder))
+	text.WriteString("Create the document with create_from_template and the standard template, so that it has frontmatter and Overview, Details and Related sections. ")
+	fmt.Fprintf(&text, "Available templates: %s; list_templates shows their variables.\n\n", strings.Join(names, ", "))
+	text.WriteString("Explain what the feature does, how to use it and how it fits with the rest of the system. ")
+	text.WriteString("Link related documents, and validate the result with validate_markdown_file.")
+	if len(related) > 0 {
+		text.WriteString("\n\nThese documents already mention the feature. If one of them covers it, extend it with edit_section or edit_markdown_file instead of creating a new document:")
+		for _, r := range related {
+			fmt.Fprintf(&text, "\n- %s (%s)", r.Title, r.Path)
+		}
+	}
+
+	messages := []*mcp.PromptMessage{promptText(text.String())}
+	for _, r := range related {
+		message, err := promptDocument(root, r.Path)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+	return &mcp.GetPromptResult{
+		Description: "Document " + feature,
+		Messages:    messages,
+	}, nil
+}
+
+// SummarizeFolderPromptLogic builds the summarize_folder prompt: a request
+// to summarize the documents of folder, relative to root, which are
+// embedded along with their validation warnings.
+func SummarizeFolderPromptLogic(root, folder string) (*mcp.GetPromptResult, error) {
+	if folder == "" {
+		folder = "."
+	}
+	files, err := promptFiles(root, folder)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%s has no markdown files", folder)
+	}
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "Summarize the documentation in %s: what it covers, how the documents relate to each other, and what is missing, outdated or duplicated. ", promptFolder(folder))
+	text.WriteString("Start with a short overview, then give one or two sentences per document.\n\n")
+	warnings, err := collectWarnings(root, files)
+	if err != nil {
+		return nil, err
+	}
+	text.WriteString(promptWarnings(files, warnings))
+	if len(files) > maxPromptDocuments {
+		fmt.Fprintf(&text, "\n\nOnly the first %d of %d documents are attached; read the others with the doc tools as needed.", maxPromptDocuments, len(files))
+		files = files[:maxPromptDocuments]
+	}
+
+	messages := []*mcp.PromptMessage{promptText(text.String())}
+	for _, file := range files {
+		message, err := promptDocument(root, file)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+	return &mcp.GetPromptResult{
+		Description: "Summarize " + promptFolder(folder),
+		Messages:    messages,
+	}, nil
+}
+
+// FixValidationWarningsPromptLogic builds the fix_validation_warnings
+// prompt: the current validation warnings of the markdown files at path
+// under root, a file or a folder, with the files that have warnings
+// embedded.
+func FixValidationWarningsPromptLogic(root, path string) (*mcp.GetPromptResult, error) {
+	if path == "" {
+		path = "."
+	}
+	files, err := promptFiles(root, path)
+	if err != nil {
+		return nil, err
+	}
+
+	warnings, err := collectWarnings(root, files)
+	if err != nil {
+		return nil, err
+	}
+	flagged := []string{}
+	for _, file := range files {
+		if len(warnings[file]) > 0 {
+			flagged = append(flagged, file)
+		}
+	}
+	if len(flagged) == 0 {
+		return &mcp.GetPromptResult{
+			Description: "Fix validation warnings in " + promptFolder(path),
+			Messages:    []*mcp.PromptMessage{promptText(fmt.Sprintf("The documents in %s have no validation warnings. Nothing needs fixing.", promptFolder(path)))},
+		}, nil
+	}
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "Fix the validation warnings of the documents in %s. ", promptFolder(path))
+	text.WriteString("Edit each file with edit_section or edit_markdown_file, keeping its meaning; update_toc refreshes stale tables of contents. ")
+	text.WriteString("Run validate_markdown_file on the result, and explain any warning that should stay.\n\n")
+	text.WriteString(promptWarnings(flagged, warnings))
+	if len(flagged) > maxPromptDocuments {
+		fmt.Fprintf(&text, "\n\nOnly the first %d of %d files with warnings are attached.", maxPromptDocuments, len(flagged))
+		flagged = flagged[:maxPromptDocuments]
+	}
+
+	messages := []*mcp.PromptMessage{promptText(text.String())}
+	for _, file := range flagged {
+		message, err := promptDocument(root, file)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+	return &mcp.GetPromptResult{
+		Description: "Fix validation warnings in " + promptFolder(path),
+		Messages:    messages,
+	}, nil
+}
+
+// WriteADRPromptLogic builds the write_adr prompt: a request to record the
+// decision title as the next numbered ADR under root, with the latest
+// records embedded for their format and for decisions it may supersede.
+func WriteADRPromptLogic(root, title, context string) (*mcp.GetPromptResult, error) {
+	if strings.TrimSpace(title) == "" {
+		return nil, fmt.Errorf("title is required")
+	}
+
+	folder := adrFolders[0]
+	for _, candidate := range adrFolders {
+		if info, err := os.Stat(filepath.Join(root, candidate)); err == nil && info.IsDir() {
+			folder = candidate
+			break
+		}
+	}
+	records := []string{}
+	next := 1
+	if files, err := promptFiles(root, folder); err == nil {
+		for _, file := range files {
+			m := adrNumberRe.FindStringSubmatch(filepath.Base(file))
+			if m == nil {
+				continue
+			}
+			records = append(records, file)
+			if n, _ := strconv.Atoi(m[1]); n >= next {
+				next = n + 1
+			}
+		}
+	}
+	sort.Strings(records)
+	slug := headingSlug(title)
+	if slug == "" {
+		slug = defaultADRSlug
+	}
+	docPath := fmt.Sprintf("%s/%04d-%s.md", folder, next, slug)
+
+	template := defaultTemplateName
+	if _, err := os.Stat(filepath.Join(root, templatesFolderName, "adr.md")); err == nil {
+		template = "adr"
+	}
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "Write an architecture decision record for: %s.\n\n", title)
+	if context != "" {
+		fmt.Fprintf(&text, "Context given: %s\n\n", context)
+	}
+	fmt.Fprintf(&text, "Create it as %s with create_from_template and the %s template. ", docPath, template)
+	text.WriteString("Cover the context and problem, the options considered, the decision and its consequences, and a status of proposed, accepted or superseded. ")
+	text.WriteString("Keep it short and factual; ask for anything the decision depends on that is not known.")
+	if len(records) > 0 {
+		text.WriteString("\n\nThe latest records are attached. Follow their format, and if the new decision supersedes one of them, update its status and link the two.")
+	}
+
+	if len(records) > 3 {
+		records = records[len(records)-3:]
+	}
+	messages := []*mcp.PromptMessage{promptText(text.String())}
+	for _, file := range records {
+		message, err := promptDocument(root, file)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+	return &mcp.GetPromptResult{
+		Description: "Write an ADR: " + title,
+		Messages:    messages,
+	}, nil
+}
+
+// promptFiles lists the markdown files at path under root, a file or a
+// folder, as paths relative to root.
+func promptFiles(root, path string) ([]string, error) {
+	target, err := resolveInRoot(root, path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(target)
+	if err != nil {
+		return nil, fmt.Errorf("%s not found: %w", path, err)
+	}
+	if !info.IsDir() {
+		if !strings.HasSuffix(target, ".md") {
+			return nil, fmt.Errorf("%s is not a markdown file", path)
+		}
+		return []string{rootRelPath(root, target)}, nil
+	}
+
+	files, err := markdownFilesIn(target)
+	if err != nil {
+		return nil, err
+	}
+	rels := []string{}
+	for _, file := range files {
+		if rel := rootRelPath(root, file); rel != "" {
+			rels = append(rels, rel)
+		}
+	}
+	return rels, nil
+}
+
+// documentWarnings validates the document at rel under root as the create
+// and edit tools do.
+func documentWarnings(root, rel string) ([]string, error) {
+	file := filepath.Join(root, filepath.FromSlash(rel))
+	source, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %w", rel, err)
+	}
+	warnings := validateMarkdown(string(source))
+	return append(warnings, validateLinkedAnchors(string(source), filepath.Dir(file))...), nil
+}
+
+// collectWarnings validates each of files, relative to root, keyed by file.
+func collectWarnings(root string, files []string) (map[string][]string, error) {
+	warnings := make(map[string][]string)
+	for _, file := range files {
+		found, err := documentWarnings(root, file)
+		if err != nil {
+			return nil, err
+		}
+		warnings[file] = found
+	}
+	return warnings, nil
+}
+
+// promptWarnings describes the validation warnings of files, as computed by
+// collectWarnings.
+func promptWarnings(files []string, warnings map[string][]string) string {
+	var text strings.Builder
+	text.WriteString("Current validation results:")
+	clean := 0
+	for _, file := range files {
+		if len(warnings[file]) == 0 {
+			clean++
+			continue
+		}
+		fmt.Fprintf(&text, "\n- %s:", file)
+		for _, w := range warnings[file] {
+			fmt.Fprintf(&text, "\n  - %s", w)
+		}
+	}
+	if clean == len(files) {
+		text.WriteString(" no warnings.")
+	} else if clean > 0 {
+		fmt.Fprintf(&text, "\n- %d other documents have no warnings.", clean)
+	}
+	return text.String()
+}
+
+func promptFolder(folder string) string {
+	if folder == "." {
+		return "doc/"
+	}
+	return "doc/" + strings.TrimSuffix(filepath.ToSlash(folder), "/")
+}
+
+func promptText(text string) *mcp.PromptMessage {
+	return &mcp.PromptMessage{Role: "user", Content: &mcp.TextContent{Text: text}}
+}
+
+// promptDocument embeds the document at rel under root as a resource.
+func promptDocument(root, rel string) (*mcp.PromptMessage, error) {
+	file := filepath.Join(root, filepath.FromSlash(rel))
+	source, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %w", rel, err)
+	}
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return nil, err
+	}
+	return &mcp.PromptMessage{
+		Role: "user",
+		Content: &mcp.EmbeddedResource{Resource: &mcp.ResourceContents{
+			URI:      "file://" + filepath.ToSlash(abs),
+			MIMEType: "text/markdown",
+			Text:     string(source),
+		}},
+	}, nil
+}
@@ -1,37 +1,398 @@
 package server
 
 import (
-	"bytes"
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
-	"github.com/will-wow/larkdown"
 	"github.com/yuin/goldmark"
-	"github.com/yuin/goldmark/ast"
-	"github.com/yuin/goldmark/text"
 )
 
-func RefactorFolderLogic(folderPath string) error {
+const maxFolderItems = 10
+
+const (
+	StrategyPrefix   = "prefix"
+	StrategySemantic = "semantic"
+	StrategyTag      = "tag"
+)
+
+const defaultGroupKey = "category"
+
+// RefactorOptions selects how RefactorFolderLogic groups files into folders.
+// Strategy defaults to StrategyPrefix. Clusters is the number of folders the
+// semantic strategy aims for; zero picks one per 10 files. GroupKey is the
+// frontmatter key the tag strategy groups by, "category" when empty. Indexes
+// regenerates the index.md of every folder once the files are in place.
+type RefactorOptions struct {
+	Strategy string
+	Clusters int
+	GroupKey string
+	Indexes  bool
+}
+
+// RefactorMove relocates one file or folder during a refactor.
+type RefactorMove struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// refactorUnit is something that moves as a whole when a folder is
+// rebalanced: an existing entry, or a group of files that gets its own folder.
+type refactorUnit struct {
+	name    string
+	members []string
+	wrap    bool
+}
+
+// RefactorFolderLogic regroups folderPath and every folder below it until no
+// folder holds more than 10 items, counting files and subfolders alike.
+// Links to the moved files are rewritten in every markdown file under root,
+// the doc root containing folderPath.
+func RefactorFolderLogic(root, folderPath string, opts RefactorOptions) error {
+	_, err := refactorFolder(root, folderPath, opts)
+	return err
+}
+
+// refactorFolder does the work of RefactorFolderLogic and returns the moved
+// files, mapping their old absolute paths to their new ones.
+func refactorFolder(root, folderPath string, opts RefactorOptions) (map[string]string, error) {
+	entries, err := folderEntries(root, folderPath)
+	if err != nil {
+		return nil, err
+	}
+
+	movedFiles := make(map[string]string)
+	if err := refactorTree(root, folderPath, opts, movedFiles); err != nil {
+		return movedFiles, err
+	}
+
+	if err := carryAssets(root, movedFiles); err != nil {
+		return movedFiles, err
+	}
+
+	if len(movedFiles) == 0 {
+		return movedFiles, fmt.Errorf("folder %s has %d items and no subfolder exceeds the limit, no refactoring needed (threshold is >%d)", folderPath, len(entries), maxFolderItems)
+	}
+
+	if _, err := updateLinksLogic(root, movedFiles); err != nil {
+		return movedFiles, fmt.Errorf("failed to update links: %w", err)
+	}
+
+	if opts.Indexes {
+		if _, err := GenerateIndexesLogic(root, folderPath); err != nil {
+			return movedFiles, err
+		}
+	}
+
+	return movedFiles, nil
+}
+
+// RefactorSuggestion describes a folder over the item limit and the first
+// level of moves a refactor makes to fix it.
+type RefactorSuggestion struct {
+	Folder   string         `json:"folder"`
+	Items    int            `json:"items"`
+	Limit    int            `json:"limit"`
+	Strategy string         `json:"strategy"`
+	Applied  bool           `json:"applied"`
+	Moves    []RefactorMove `json:"moves"`
+	// Moved maps the old absolute path of every file an applied refactor
+	// moved to its new one.
+	Moved map[string]string `json:"-"`
+}
+
+// CheckFolderLimitLogic checks folderPath against the item limit. Within the
+// limit, or with mode AutoRefactorOff, it returns nil. Otherwise it returns the
+// planned moves and, with mode AutoRefactorApply, refactors the folder and
+// rewrites links to it under root.
+func CheckFolderLimitLogic(root, folderPath, mode string, opts RefactorOptions) (*RefactorSuggestion, error) {
+	if mode == "" || mode == AutoRefactorOff {
+		return nil, nil
+	}
+	if mode != AutoRefactorSuggest && mode != AutoRefactorApply {
+		return nil, fmt.Errorf("unknown auto refactor mode %q", mode)
+	}
+
+	entries, err := folderEntries(root, folderPath)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) <= maxFolderItems {
+		return nil, nil
+	}
+
+	moves, err := planFolder(root, folderPath, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	strategy := opts.Strategy
+	if strategy == "" {
+		strategy = StrategyPrefix
+	}
+	suggestion := &RefactorSuggestion{
+		Folder:   folderPath,
+		Items:    len(entries),
+		Limit:    maxFolderItems,
+		Strategy: strategy,
+		Moves:    moves,
+	}
+
+	if mode == AutoRefactorApply {
+		if suggestion.Moved, err = refactorFolder(root, folderPath, opts); err != nil {
+			return suggestion, err
+		}
+		suggestion.Applied = true
+	}
+
+	return suggestion, nil
+}
+
+func refactorTree(root, folderPath string, opts RefactorOptions, movedFiles map[string]string) error {
+	moves, err := planFolder(root, folderPath, opts)
+	if err != nil {
+		return err
+	}
+
+	if err := applyMoves(moves, movedFiles); err != nil {
+		return err
+	}
+
+	entries, err := folderEntries(root, folderPath)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			if err := refactorTree(root, filepath.Join(folderPath, entry.Name()), opts, movedFiles); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// folderEntries lists the entries that count towards a folder's item limit.
+// Hidden files and folders are ignored, and so are the folder's index.md, the
+// assets folder of its documents, which always stays with them, and the
+// templates folder of root.
+func folderEntries(root, folderPath string) ([]os.DirEntry, error) {
 	entries, err := os.ReadDir(folderPath)
 	if err != nil {
-		return fmt.Errorf("failed to read directory %s: %w", folderPath, err)
+		return nil, fmt.Errorf("failed to read directory %s: %w", folderPath, err)
 	}
 
-	markdownFiles := []os.DirEntry{}
+	hasDocuments := false
 	for _, entry := range entries {
 		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".md") {
+			hasDocuments = true
+		}
+	}
+
+	visible := []os.DirEntry{}
+	for _, entry := range entries {
+		switch {
+		case strings.HasPrefix(entry.Name(), "."), entry.Name() == indexFileName:
+		case entry.IsDir() && entry.Name() == assetsFolderName && hasDocuments:
+		case entry.IsDir() && isTemplatesFolder(root, filepath.Join(folderPath, entry.Name())):
+		default:
+			visible = append(visible, entry)
+		}
+	}
+	return visible, nil
+}
+
+// planFolder computes the moves that bring a single folder within the item
+// limit. Markdown files are grouped with the configured strategy; when the
+// groups alone still exceed the limit, groups and subfolders are nested into
+// an extra hierarchy level of alphabetical buckets. Other files stay put.
+func planFolder(root, folderPath string, opts RefactorOptions) ([]RefactorMove, error) {
+	entries, err := folderEntries(root, folderPath)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) <= maxFolderItems {
+		return nil, nil
+	}
+
+	markdownFiles := []os.DirEntry{}
+	units := []refactorUnit{}
+	used := make(map[string]bool)
+	fixed := 0
+	for _, entry := range entries {
+		used[entry.Name()] = true
+		switch {
+		case entry.IsDir():
+			units = append(units, refactorUnit{name: entry.Name(), members: []string{filepath.Join(folderPath, entry.Name())}})
+		case strings.HasSuffix(entry.Name(), ".md"):
 			markdownFiles = append(markdownFiles, entry)
+		default:
+			fixed++
+		}
+	}
+
+	groups, err := groupFiles(folderPath, markdownFiles, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	groupNames := make([]string, 0, len(groups))
+	for name := range groups {
+		groupNames = append(groupNames, name)
+	}
+	sort.Strings(groupNames)
+
+	for _, name := range groupNames {
+		groupFiles := groups[name]
+		// A group holding every entry of the folder would only push the
+		// problem one level down, and one without a name, such as files
+		// starting with "_", has no folder to go to, so their files are
+		// bucketed below instead.
+		if name != "" && len(groupFiles) > 1 && len(groupFiles) < len(entries) {
+			unit := refactorUnit{name: uniqueName(used, name), wrap: true}
+			for _, file := range groupFiles {
+				unit.members = append(unit.members, filepath.Join(folderPath, file.Name()))
+			}
+			units = append(units, unit)
+			continue
+		}
+		for _, file := range groupFiles {
+			units = append(units, refactorUnit{name: strings.TrimSuffix(file.Name(), ".md"), members: []string{filepath.Join(folderPath, file.Name())}})
+		}
+	}
+
+	sort.Slice(units, func(i, j int) bool { return units[i].name < units[j].name })
+
+	available := maxFolderItems - fixed
+	if len(units) <= available {
+		return unitMoves(folderPath, units), nil
+	}
+	if available < 2 {
+		return nil, fmt.Errorf("folder %s has %d non-markdown files, leaving no room to group its entries under the limit of %d", folderPath, fixed, maxFolderItems)
+	}
+
+	buckets := (len(units) + maxFolderItems - 1) / maxFolderItems
+	if buckets > available {
+		buckets = available
+	}
+	size := (len(units) + buckets - 1) / buckets
+
+	moves := []RefactorMove{}
+	for start := 0; start < len(units); start += size {
+		end := start + size
+		if end > len(units) {
+			end = len(units)
+		}
+		chunk := units[start:end]
+		if len(chunk) == 1 {
+			moves = append(moves, unitMoves(folderPath, chunk)...)
+			continue
 		}
+
+		bucket := folderName(chunk[0].name) + "-" + folderName(chunk[len(chunk)-1].name)
+		moves = append(moves, unitMoves(filepath.Join(folderPath, uniqueName(used, bucket)), chunk)...)
 	}
 
-	if len(markdownFiles) <= 10 {
-		return fmt.Errorf("folder %s has %d markdown files, no refactoring needed (threshold is >10)", folderPath, len(markdownFiles))
+	return moves, nil
+}
+
+func groupFiles(folderPath string, files []os.DirEntry, opts RefactorOptions) (map[string][]os.DirEntry, error) {
+	switch opts.Strategy {
+	case "", StrategyPrefix:
+		return groupByPrefix(files), nil
+	case StrategySemantic:
+		return groupBySimilarity(folderPath, files, opts.Clusters)
+	case StrategyTag:
+		return groupByTag(folderPath, files, opts.GroupKey)
+	default:
+		return nil, fmt.Errorf("unknown refactor strategy %q", opts.Strategy)
 	}
+}
 
+// unitMoves places every unit inside dest, wrapping grouped files in a folder
+// named after their group.
+func unitMoves(dest string, units []refactorUnit) []RefactorMove {
+	moves := []RefactorMove{}
+	for _, unit := range units {
+		target := dest
+		if unit.wrap {
+			target = filepath.Join(dest, unit.name)
+		}
+		for _, member := range unit.members {
+			to := filepath.Join(target, filepath.Base(member))
+			if to != member {
+				moves = append(moves, RefactorMove{From: member, To: to})
+			}
+		}
+	}
+	return moves
+}
+
+func uniqueName(used map[string]bool, name string) string {
+	unique := name
+	for n := 2; used[unique]; n++ {
+		unique = fmt.Sprintf("%s%d", name, n)
+	}
+	used[unique] = true
+	return unique
+}
+
+// applyMoves performs the moves and records where every file ended up,
+// keyed by the absolute path it had before the refactor started.
+func applyMoves(moves []RefactorMove, movedFiles map[string]string) error {
+	for _, move := range moves {
+		if err := os.MkdirAll(filepath.Dir(move.To), 0755); err != nil {
+			return fmt.Errorf("failed to create directory %s: %w", filepath.Dir(move.To), err)
+		}
+
+		files := []string{}
+		err := filepath.WalkDir(move.From, func(path string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to list files in %s: %w", move.From, err)
+		}
+
+		if err := os.Rename(move.From, move.To); err != nil {
+			return fmt.Errorf("failed to move file from %s to %s: %w", move.From, move.To, err)
+		}
+
+		for _, oldPath := range files {
+			rel, _ := filepath.Rel(move.From, oldPath)
+			recordMove(movedFiles, oldPath, filepath.Join(move.To, rel))
+		}
+	}
+
+	return nil
+}
+
+func recordMove(movedFiles map[string]string, oldPath, newPath string) {
+	absOldPath, _ := filepath.Abs(oldPath)
+	absNewPath, _ := filepath.Abs(newPath)
+	for original, current := range movedFiles {
+		if current == absOldPath {
+			movedFiles[original] = absNewPath
+			return
+		}
+	}
+	movedFiles[absOldPath] = absNewPath
+}
+
+// groupByPrefix groups files by the part of their name before the first "_"
+// or "-". Files without either separator go to "common".
+func groupByPrefix(files []os.DirEntry) map[string][]os.DirEntry {
 	groups := make(map[string][]os.DirEntry)
-	for _, file := range markdownFiles {
+	for _, file := range files {
 		filename := file.Name()
 		baseName := strings.TrimSuffix(filename, filepath.Ext(filename))
 
@@ -46,89 +407,179 @@ func RefactorFolderLogic(folderPath string) error {
 		groups[key] = append(groups[key], file)
 	}
 
-	movedFiles := make(map[string]string)
+	return groups
+}
 
-	for groupName, groupFiles := range groups {
-		if len(groupFiles) > 1 {
-			newDir := filepath.Join(folderPath, groupName)
-			if err := os.MkdirAll(newDir, 0755); err != nil {
-				return fmt.Errorf("failed to create directory %s: %w", newDir, err)
-			}
+// groupByTag groups files by the value of a frontmatter key, or by their most
+// frequent #hashtag when the key is missing. Untagged files fall back to the
+// prefix heuristic.
+func groupByTag(folderPath string, files []os.DirEntry, groupKey string) (map[string][]os.DirEntry, error) {
+	if groupKey == "" {
+		groupKey = defaultGroupKey
+	}
 
-			for _, fileToMove := range groupFiles {
-				oldPath := filepath.Join(folderPath, fileToMove.Name())
-				newPath := filepath.Join(newDir, fileToMove.Name())
-				if err := os.Rename(oldPath, newPath); err != nil {
-					return fmt.Errorf("failed to move file from %s to %s: %w", oldPath, newPath, err)
-				}
-				absOldPath, _ := filepath.Abs(oldPath)
-				absNewPath, _ := filepath.Abs(newPath)
-				movedFiles[absOldPath] = absNewPath
+	groups := make(map[string][]os.DirEntry)
+	untagged := []os.DirEntry{}
+	for _, file := range files {
+		source, err := os.ReadFile(filepath.Join(folderPath, file.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file %s: %w", file.Name(), err)
+		}
+
+		meta, body := splitFrontmatter(source)
+		key := folderName(frontmatterValue(meta, groupKey))
+		if key == "" {
+			if tags := hashtags(body); len(tags) > 0 {
+				key = folderName(tags[0])
 			}
 		}
+
+		if key == "" {
+			untagged = append(untagged, file)
+			continue
+		}
+		groups[key] = append(groups[key], file)
 	}
 
-	if err := updateLinksLogic(movedFiles); err != nil {
-		return fmt.Errorf("failed to update links: %w", err)
+	for key, groupFiles := range groupByPrefix(untagged) {
+		groups[key] = append(groups[key], groupFiles...)
 	}
 
-	return nil
+	return groups, nil
+}
+
+// updateLinksLogic rewrites relative links and images in every markdown file
+// under root after the moves in movedFiles, which maps absolute paths before
+// the move to absolute paths after it. Links are fixed both when the linking
+// file moved and when its target did. It returns the files that were
+// rewritten.
+func updateLinksLogic(root string, movedFiles map[string]string) ([]string, error) {
+	return rewriteLinks(root, movedFiles, movedFiles)
 }
 
-func updateLinksLogic(movedFiles map[string]string) error {
+// rewriteLinks is the core of updateLinksLogic. relocated maps the old
+// absolute path of files that moved to their new one, so that their own
+// links can be resolved from where they used to be. retargeted maps absolute
+// link targets to the absolute path links should point at instead.
+func rewriteLinks(root string, relocated, retargeted map[string]string) ([]string, error) {
 	mdParser := goldmark.New()
-	mdRenderer := goldmark.New(
-		goldmark.WithRenderer(larkdown.NewNodeRenderer()),
-	)
 
-	for oldPath, newPath := range movedFiles {
+	originalPaths := make(map[string]string, len(relocated))
+	for oldPath, newPath := range relocated {
+		originalPaths[newPath] = oldPath
+	}
+
+	files, err := markdownFilesIn(root)
+	if err != nil {
+		return nil, err
+	}
+
+	oldWiki, err := wikiResolverFor(root, originalPaths, nil)
+	if err != nil {
+		return nil, err
+	}
+	newWiki, err := wikiResolverFor(root, nil, retargeted)
+	if err != nil {
+		return nil, err
+	}
+	wikiTarget := func(old string) string {
+		if dest, ok := retargeted[old]; ok {
+			return dest
+		}
+		return old
+	}
+
+	updated := []string{}
+	for _, file := range files {
+		newPath, err := filepath.Abs(file)
+		if err != nil {
+			return updated, err
+		}
+		oldPath, moved := originalPaths[newPath]
+		if !moved {
+			oldPath = newPath
+		}
+
 		source, err := os.ReadFile(newPath)
 		if err != nil {
-			return fmt.Errorf("failed to read file %s: %w", newPath, err)
+			return updated, fmt.Errorf("failed to read file %s: %w", newPath, err)
 		}
 
-		doc := mdParser.Parser().Parse(text.NewReader(source))
+		_, links := parseLinks(mdParser, source)
 
-		walkErr := ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
-			if !entering {
-				return ast.WalkContinue, nil
+		var rewriteErr error
+		edits := links.retarget(func(dest string) (string, bool) {
+			if !isLocalDest(dest) {
+				return "", false
 			}
+			destPath, suffix := splitLinkDest(dest)
 
-			if link, ok := n.(*ast.Link); ok {
-				dest := string(link.Destination)
-				if !strings.HasSuffix(dest, ".md") || strings.HasPrefix(dest, "http") {
-					return ast.WalkContinue, nil
-				}
-
-				linkAbsPath, err := filepath.Abs(filepath.Join(filepath.Dir(oldPath), dest))
-				if err != nil {
-					return ast.WalkStop, err
-				}
+			linkAbsPath, err := filepath.Abs(filepath.Join(filepath.Dir(oldPath), unescapedPath(destPath)))
+			if err != nil {
+				rewriteErr = err
+				return "", false
+			}
 
-				if newDestAbs, ok := movedFiles[linkAbsPath]; ok {
-					newRelDest, err := filepath.Rel(filepath.Dir(newPath), newDestAbs)
-					if err != nil {
-						return ast.WalkStop, err
-					}
-					link.Destination = []byte(newRelDest)
+			newDestAbs, targetMoved := retargeted[linkAbsPath]
+			if !targetMoved {
+				if !moved {
+					return "", false
 				}
+				newDestAbs = linkAbsPath
 			}
 
-			return ast.WalkContinue, nil
+			newRelDest, err := filepath.Rel(filepath.Dir(newPath), newDestAbs)
+			if err != nil {
+				rewriteErr = err
+				return "", false
+			}
+			newRelDest = filepath.ToSlash(newRelDest)
+			if decoded, err := url.PathUnescape(destPath); err == nil && decoded != destPath {
+				newRelDest = (&url.URL{Path: newRelDest}).EscapedPath()
+			}
+			return newRelDest + suffix, true
 		})
-		if walkErr != nil {
-			return fmt.Errorf("error walking ast for %s: %w", newPath, walkErr)
+		if rewriteErr != nil {
+			return updated, fmt.Errorf("failed to rewrite links in %s: %w", newPath, rewriteErr)
 		}
 
-		var buf bytes.Buffer
-		if err := mdRenderer.Renderer().Render(&buf, source, doc); err != nil {
-			return fmt.Errorf("failed to render markdown for %s: %w", newPath, err)
+		changed := len(edits) > 0
+		output := applyEdits(source, edits)
+		output, wikiChanged := rewriteWikiLinks(root, output, oldWiki, newWiki, wikiTarget)
+		if !changed && !wikiChanged {
+			continue
 		}
 
-		if err := os.WriteFile(newPath, buf.Bytes(), 0644); err != nil {
-			return fmt.Errorf("failed to write updated markdown to %s: %w", newPath, err)
+		if err := os.WriteFile(newPath, endWithNewline(output), 0644); err != nil {
+			return updated, fmt.Errorf("failed to write updated markdown to %s: %w", newPath, err)
 		}
+		updated = append(updated, file)
 	}
 
-	return nil
-} 
\ No newline at end of file
+	return updated, nil
+}
+
+// markdownFilesIn lists the markdown files under root, skipping hidden
+// folders and the templates folder.
+func markdownFilesIn(root string) ([]string, error) {
+	files := []string{}
+	err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != root && (strings.HasPrefix(d.Name(), ".") || isTemplatesFolder(root, path)) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasSuffix(d.Name(), ".md") {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list markdown files in %s: %w", root, err)
+	}
+	return files, nil
+}
@@ -0,0 +1,157 @@
+package server
+
+import (
+	"sort"
+)
+
+const (
+	maxLinkSuggestions = 3
+	maxSharedTerms     = 5
+)
+
+// LinkSuggestion is a document worth linking, with the TF-IDF similarity it
+// was chosen for and the terms the two documents share most.
+type LinkSuggestion struct {
+	Path        string   `json:"path"`
+	Title       string   `json:"title"`
+	Score       float64  `json:"score"`
+	SharedTerms []string `json:"shared_terms"`
+}
+
+// ReportEntry is a document flagged by the link report. For an orphan the
+// suggestions are documents that could link to it; for a dead end they are
+// documents it could link to.
+type ReportEntry struct {
+	Path        string           `json:"path"`
+	Title       string           `json:"title"`
+	Suggestions []LinkSuggestion `json:"suggestions"`
+}
+
+// LinkReport lists the documents that are hard to reach or lead nowhere.
+// Components holds the groups of documents connected by links, largest
+// first, when there is more than one.
+type LinkReport struct {
+	Documents  int           `json:"documents"`
+	Orphans    []ReportEntry `json:"orphans"`
+	DeadEnds   []ReportEntry `json:"dead_ends"`
+	Components [][]string    `json:"components"`
+}
+
+// Report finds the orphans (no links from other documents), dead ends (no
+// links to other existing documents) and disconnected components of the
+// graph, suggesting link targets from shared terms.
+func (g *LinkGraph) Report() *LinkReport {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	docs := g.sortedDocs()
+	inbound := make(map[string]bool)
+	outbound := make(map[string]bool)
+	for _, doc := range docs {
+		for _, link := range doc.Links {
+			if _, ok := g.docs[link.Target]; ok && link.Target != doc.Path {
+				inbound[link.Target] = true
+				outbound[doc.Path] = true
+			}
+		}
+	}
+
+	terms := make([][]string, len(docs))
+	for i, doc := range docs {
+		terms[i] = doc.Terms
+	}
+	vectors := tfidfVectors(terms)
+
+	report := &LinkReport{Documents: len(docs), Orphans: []ReportEntry{}, DeadEnds: []ReportEntry{}, Components: [][]string{}}
+	for i, doc := range docs {
+		if !inbound[doc.Path] {
+			report.Orphans = append(report.Orphans, ReportEntry{Path: doc.Path, Title: doc.Title, Suggestions: suggestSimilar(docs, vectors, i)})
+		}
+		if !outbound[doc.Path] {
+			report.DeadEnds = append(report.DeadEnds, ReportEntry{Path: doc.Path, Title: doc.Title, Suggestions: suggestSimilar(docs, vectors, i)})
+		}
+	}
+
+	if components := g.components(docs); len(components) > 1 {
+		report.Components = components
+	}
+	return report
+}
+
+// suggestSimilar returns the documents most similar to docs[i].
+func suggestSimilar(docs []*graphDoc, vectors []termVector, i int) []LinkSuggestion {
+	suggestions := []LinkSuggestion{}
+	for j, other := range docs {
+		if j == i {
+			continue
+		}
+		score := cosine(vectors[i], vectors[j])
+		if score <= 0 {
+			continue
+		}
+		suggestions = append(suggestions, LinkSuggestion{
+			Path:        other.Path,
+			Title:       other.Title,
+			Score:       roundScore(score),
+			SharedTerms: sharedTerms(vectors[i], vectors[j], maxSharedTerms),
+		})
+	}
+	sort.SliceStable(suggestions, func(a, b int) bool { return suggestions[a].Score > suggestions[b].Score })
+	if len(suggestions) > maxLinkSuggestions {
+		suggestions = suggestions[:maxLinkSuggestions]
+	}
+	return suggestions
+}
+
+// sharedTerms returns the n terms contributing most to the similarity of
+// two vectors.
+func sharedTerms(a, b termVector, n int) []string {
+	product := termVector{}
+	for term, w := range a {
+		if b[term] > 0 {
+			product[term] = w * b[term]
+		}
+	}
+	return product.topTerms(n)
+}
+
+// components groups the documents connected by links in either direction,
+// largest group first.
+func (g *LinkGraph) components(docs []*graphDoc) [][]string {
+	adjacent := g.undirected()
+	seen := make(map[string]bool)
+	components := [][]string{}
+	for _, doc := range docs {
+		if seen[doc.Path] {
+			continue
+		}
+		component := []string{}
+		stack := []string{doc.Path}
+		seen[doc.Path] = true
+		for len(stack) > 0 {
+			p := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			component = append(component, p)
+			for _, q := range adjacent[p] {
+				if !seen[q] {
+					seen[q] = true
+					stack = append(stack, q)
+				}
+			}
+		}
+		sort.Strings(component)
+		components = append(components, component)
+	}
+	sort.SliceStable(components, func(i, j int) bool { return len(components[i]) > len(components[j]) })
+	return components
+}
+
+// LinkReportLogic reports the orphans, dead ends and disconnected components
+// of the documents under root.
+func LinkReportLogic(root string) (*LinkReport, error) {
+	g, err := linkGraphFor(root)
+	if err != nil {
+		return nil, err
+	}
+	return g.Report(), nil
+}
@@ -0,0 +1,893 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+const (
+	bm25K1           = 1.2
+	bm25B            = 0.75
+	maxSnippets      = 3
+	defaultMaxHits   = 10
+	titleTermBoost   = 2
+	headingTermBoost = 1
+	indexVersion     = 1
+)
+
+// SearchResult is a document matching a search, with the lines that matched.
+type SearchResult struct {
+	Path     string    `json:"path"`
+	Title    string    `json:"title"`
+	Score    float64   `json:"score"`
+	Snippets []Snippet `json:"snippets"`
+}
+
+// Snippet is a matching line of a document, numbered from 1.
+type Snippet struct {
+	Line int    `json:"line"`
+	Text string `json:"text"`
+}
+
+// indexedDoc is the analysed form of one document. ModTime and Hash record
+// the file it was analysed from so unchanged files can skip reindexing.
+type indexedDoc struct {
+	Path     string   `json:"path"`
+	ModTime  int64    `json:"mod_time"`
+	Hash     string   `json:"hash"`
+	Title    string   `json:"title"`
+	Headings []string `json:"headings"`
+	Tags     []string `json:"tags"`
+	Lines    []string `json:"lines"`
+	Terms    []string `json:"terms"`
+	TermLine []int    `json:"term_lines"`
+}
+
+// SearchIndex is an inverted index over the markdown files under a root
+// folder, ranked with BM25. It lives in memory and is persisted under
+// root/.doc-mcp/index so that restarts only reindex changed files. It also
+// keeps the MinHash signature of every document, bucketed by band, for
+// duplicate detection.
+type SearchIndex struct {
+	mu         sync.RWMutex
+	root       string
+	docs       map[string]*indexedDoc
+	postings   map[string]map[string][]int
+	signatures map[string]*minHashSignature
+	bands      map[uint64]map[string]bool
+	length     int
+	stats      IndexStats
+	saveTimer  *time.Timer
+}
+
+// searchIndexSaveDelay is how long Update waits before saving the index, so
+// that a burst of changes is written once.
+const searchIndexSaveDelay = 2 * time.Second
+
+// IndexStats describes the state of a search index and what its last load
+// or rebuild did.
+type IndexStats struct {
+	Path      string    `json:"path"`
+	Documents int       `json:"documents"`
+	Terms     int       `json:"terms"`
+	Tokens    int       `json:"tokens"`
+	Reused    int       `json:"reused"`
+	Reindexed int       `json:"reindexed"`
+	Removed   int       `json:"removed"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// persistedIndex is the on-disk form of a search index.
+type persistedIndex struct {
+	Version int           `json:"version"`
+	Docs    []*indexedDoc `json:"docs"`
+}
+
+var (
+	searchIndexesMu sync.Mutex
+	searchIndexes   = make(map[string]*SearchIndex)
+)
+
+// NewSearchIndex returns an empty index for the markdown files under root.
+func NewSearchIndex(root string) *SearchIndex {
+	return &SearchIndex{
+		root:       root,
+		docs:       make(map[string]*indexedDoc),
+		postings:   make(map[string]map[string][]int),
+		signatures: make(map[string]*minHashSignature),
+		bands:      make(map[uint64]map[string]bool),
+	}
+}
+
+// searchIndexFor returns the shared index for root, building it on first use.
+func searchIndexFor(root string) (*SearchIndex, error) {
+	searchIndexesMu.Lock()
+	defer searchIndexesMu.Unlock()
+
+	if idx, ok := searchIndexes[root]; ok {
+		return idx, nil
+	}
+	idx := NewSearchIndex(root)
+	if err := idx.Load(); err != nil {
+		return nil, err
+	}
+	searchIndexes[root] = idx
+	return idx, nil
+}
+
+// LoadSearchIndex loads the shared index for root from disk, reindexing
+// files that changed since it was saved. Calling it at startup spares the
+// first search the wait.
+func LoadSearchIndex(root string) error {
+	_, err := searchIndexFor(root)
+	return err
+}
+
+// notifySearchIndex brings the shared index for root, if it has been built,
+// up to date with the given changed files or folders.
+func notifySearchIndex(root string, paths []string) {
+	searchIndexesMu.Lock()
+	idx, ok := searchIndexes[root]
+	searchIndexesMu.Unlock()
+	if ok {
+		if err := idx.Update(paths...); err != nil {
+			log.Printf("failed to update search index: %v", err)
+		}
+	}
+}
+
+// Build indexes every markdown file under the root from scratch and saves
+// the result.
+func (idx *SearchIndex) Build() error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	return idx.refresh(nil)
+}
+
+// Load reads the saved index and reindexes only the files whose modification
+// time and content hash no longer match. Without a saved index it builds one.
+func (idx *SearchIndex) Load() error {
+	saved := make(map[string]*indexedDoc)
+	data, err := os.ReadFile(idx.indexFile())
+	if err == nil {
+		var persisted persistedIndex
+		if json.Unmarshal(data, &persisted) == nil && persisted.Version == indexVersion {
+			for _, doc := range persisted.Docs {
+				saved[doc.Path] = doc
+			}
+		}
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read search index: %w", err)
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	return idx.refresh(saved)
+}
+
+// refresh rebuilds the in-memory index from the files under the root,
+// reusing entries from saved whose file is unchanged, and saves it.
+func (idx *SearchIndex) refresh(saved map[string]*indexedDoc) error {
+	files, err := markdownFilesIn(idx.root)
+	if err != nil {
+		return err
+	}
+
+	if idx.saveTimer != nil {
+		idx.saveTimer.Stop()
+		idx.saveTimer = nil
+	}
+	idx.docs = make(map[string]*indexedDoc)
+	idx.postings = make(map[string]map[string][]int)
+	idx.signatures = make(map[string]*minHashSignature)
+	idx.bands = make(map[uint64]map[string]bool)
+	idx.length = 0
+	stats := IndexStats{Path: idx.indexFile()}
+
+	for _, file := range files {
+		rel := idx.relPath(file)
+		info, err := os.Stat(file)
+		if rel == "" || err != nil {
+			continue
+		}
+
+		if doc, ok := saved[rel]; ok && doc.ModTime == info.ModTime().UnixNano() {
+			idx.add(doc)
+			stats.Reused++
+			continue
+		}
+
+		source, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		if doc, ok := saved[rel]; ok && doc.Hash == hashContent(source) {
+			doc.ModTime = info.ModTime().UnixNano()
+			idx.add(doc)
+			stats.Reused++
+			continue
+		}
+
+		doc := analyzeDocument(rel, source)
+		doc.ModTime = info.ModTime().UnixNano()
+		idx.add(doc)
+		stats.Reindexed++
+	}
+	for path := range saved {
+		if _, ok := idx.docs[path]; !ok {
+			stats.Removed++
+		}
+	}
+
+	idx.stats = stats
+	return idx.save()
+}
+
+// Stats reports the size of the index and what its last load, rebuild or
+// update did.
+func (idx *SearchIndex) Stats() IndexStats {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	stats := idx.stats
+	stats.Documents = len(idx.docs)
+	stats.Terms = len(idx.postings)
+	stats.Tokens = idx.length
+	return stats
+}
+
+func (idx *SearchIndex) indexFile() string {
+	return filepath.Join(idx.root, stateDir, "index", "search.json")
+}
+
+// scheduleSave saves the index once searchIndexSaveDelay has passed without
+// another call. The caller must hold idx.mu.
+func (idx *SearchIndex) scheduleSave() {
+	if idx.saveTimer != nil {
+		idx.saveTimer.Stop()
+	}
+	idx.saveTimer = time.AfterFunc(searchIndexSaveDelay, func() {
+		if err := idx.Flush(); err != nil {
+			log.Printf("failed to save search index: %v", err)
+		}
+	})
+}
+
+// Flush saves changes that Update has not saved yet.
+func (idx *SearchIndex) Flush() error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if idx.saveTimer == nil {
+		return nil
+	}
+	idx.saveTimer.Stop()
+	idx.saveTimer = nil
+	return idx.save()
+}
+
+// save writes the analysed documents to disk. Postings are cheap to rebuild
+// on load and are not stored.
+func (idx *SearchIndex) save() error {
+	persisted := persistedIndex{Version: indexVersion, Docs: make([]*indexedDoc, 0, len(idx.docs))}
+	for _, doc := range idx.docs {
+		persisted.Docs = append(persisted.Docs, doc)
+	}
+	sort.Slice(persisted.Docs, func(i, j int) bool { return persisted.Docs[i].Path < persisted.Docs[j].Path })
+
+	data, err := json.Marshal(persisted)
+	if err != nil {
+		return fmt.Errorf("failed to encode search index: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(idx.indexFile()), 0755); err != nil {
+		return fmt.Errorf("failed to create search index folder: %w", err)
+	}
+	if err := os.WriteFile(idx.indexFile(), data, 0644); err != nil {
+		return fmt.Errorf("failed to write search index: %w", err)
+	}
+	idx.stats.UpdatedAt = time.Now().UTC()
+	return nil
+}
+
+// Update reindexes the given files, or the documents in the given folders,
+// dropping the ones that no longer exist. Paths outside the root and
+// non-markdown files are ignored. The index is saved shortly afterwards,
+// once for a burst of updates, and only when a document changed.
+func (idx *SearchIndex) Update(paths ...string) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	known := make([]string, 0, len(idx.docs))
+	for rel := range idx.docs {
+		known = append(known, rel)
+	}
+
+	changed := false
+	var failed error
+	for _, path := range changedDocuments(idx.root, paths, known) {
+		rel := idx.relPath(path)
+		if rel == "" || !strings.HasSuffix(rel, ".md") || isTemplatePath(rel) {
+			continue
+		}
+
+		file := filepath.Join(idx.root, filepath.FromSlash(rel))
+		source, err := os.ReadFile(file)
+		if os.IsNotExist(err) {
+			if _, ok := idx.docs[rel]; ok {
+				idx.remove(rel)
+				changed = true
+			}
+			continue
+		}
+		if err == nil {
+			var info os.FileInfo
+			if info, err = os.Stat(file); err == nil {
+				if old, ok := idx.docs[rel]; ok && old.Hash == hashContent(source) {
+					old.ModTime = info.ModTime().UnixNano()
+					continue
+				}
+				doc := analyzeDocument(rel, source)
+				doc.ModTime = info.ModTime().UnixNano()
+				idx.remove(rel)
+				idx.add(doc)
+				changed = true
+				continue
+			}
+		}
+		idx.remove(rel)
+		changed = true
+		if failed == nil {
+			failed = fmt.Errorf("failed to reindex %s: %w", rel, err)
+		}
+	}
+
+	if changed {
+		idx.scheduleSave()
+	}
+	return failed
+}
+
+// changedDocuments expands paths, files or folders, into the markdown files
+// under root they cover, including the known documents, given relative to
+// root, that were inside a folder and may no longer exist.
+func changedDocuments(root string, paths, known []string) []string {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return paths
+	}
+
+	docs := []string{}
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if (err == nil && !info.IsDir()) || (err != nil && strings.HasSuffix(path, ".md")) {
+			docs = append(docs, path)
+			continue
+		}
+
+		prefix := rootRelPath(root, path)
+		if abs, err := filepath.Abs(path); err != nil || (prefix == "" && abs != absRoot) {
+			continue
+		}
+		if err == nil {
+			if files, err := markdownFilesIn(path); err == nil {
+				docs = append(docs, files...)
+			}
+		}
+		for _, rel := range known {
+			if prefix == "" || strings.HasPrefix(rel, prefix+"/") {
+				docs = append(docs, filepath.Join(root, filepath.FromSlash(rel)))
+			}
+		}
+	}
+	return docs
+}
+
+func (idx *SearchIndex) relPath(path string) string {
+	return rootRelPath(idx.root, path)
+}
+
+// rootRelPath returns path relative to root with forward slashes, or an
+// empty string when path is outside root or in a hidden folder.
+func rootRelPath(root, path string) string {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return ""
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return ""
+	}
+	rel, err := filepath.Rel(absRoot, absPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return ""
+	}
+	rel = filepath.ToSlash(rel)
+	for _, part := range strings.Split(rel, "/") {
+		if strings.HasPrefix(part, ".") {
+			return ""
+		}
+	}
+	return rel
+}
+
+func (idx *SearchIndex) add(doc *indexedDoc) {
+	idx.docs[doc.Path] = doc
+	idx.length += len(doc.Terms)
+	for pos, term := range doc.Terms {
+		if idx.postings[term] == nil {
+			idx.postings[term] = make(map[string][]int)
+		}
+		idx.postings[term][doc.Path] = append(idx.postings[term][doc.Path], pos)
+	}
+
+	if sig := minHash(doc.Terms); sig != nil && path.Base(doc.Path) != indexFileName {
+		idx.signatures[doc.Path] = sig
+		for _, key := range sig.bandKeys() {
+			if idx.bands[key] == nil {
+				idx.bands[key] = make(map[string]bool)
+			}
+			idx.bands[key][doc.Path] = true
+		}
+	}
+}
+
+func (idx *SearchIndex) remove(path string) {
+	doc, ok := idx.docs[path]
+	if !ok {
+		return
+	}
+	delete(idx.docs, path)
+	idx.length -= len(doc.Terms)
+	for _, term := range doc.Terms {
+		if postings, ok := idx.postings[term]; ok {
+			delete(postings, path)
+			if len(postings) == 0 {
+				delete(idx.postings, term)
+			}
+		}
+	}
+
+	if sig, ok := idx.signatures[path]; ok {
+		delete(idx.signatures, path)
+		for _, key := range sig.bandKeys() {
+			delete(idx.bands[key], path)
+			if len(idx.bands[key]) == 0 {
+				delete(idx.bands, key)
+			}
+		}
+	}
+}
+
+// searchTokens splits s into lowercase words for the search index. Unlike
+// tokenize it keeps every word so that phrases match exactly.
+func searchTokens(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// analyzeDocument parses a markdown document and extracts its searchable
+// terms, each with the line it appears on.
+func analyzeDocument(path string, source []byte) *indexedDoc {
+	meta, body := splitFrontmatter(source)
+	lineOffset := frontmatterLines(source, body)
+
+	doc := &indexedDoc{
+		Path:     path,
+		Hash:     hashContent(source),
+		Title:    docTitle(source, path),
+		Headings: []string{},
+		Tags:     docTags(meta, body),
+		Lines:    strings.Split(string(source), "\n"),
+		Terms:    []string{},
+		TermLine: []int{},
+	}
+
+	lineStarts := []int{0}
+	for i, c := range body {
+		if c == '\n' {
+			lineStarts = append(lineStarts, i+1)
+		}
+	}
+	lineOf := func(offset int) int {
+		return sort.Search(len(lineStarts), func(i int) bool { return lineStarts[i] > offset }) + lineOffset
+	}
+	addTerms := func(s string, line int) {
+		for _, term := range searchTokens(s) {
+			doc.Terms = append(doc.Terms, term)
+			doc.TermLine = append(doc.TermLine, line)
+		}
+	}
+
+	tree := goldmark.New().Parser().Parse(text.NewReader(body))
+	ast.Walk(tree, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+
+		switch node := n.(type) {
+		case *ast.Heading:
+			doc.Headings = append(doc.Headings, plainText(node, body))
+		case *ast.Text:
+			addTerms(string(node.Segment.Value(body)), lineOf(node.Segment.Start))
+		case *ast.CodeSpan:
+			for child := node.FirstChild(); child != nil; child = child.NextSibling() {
+				if t, ok := child.(*ast.Text); ok {
+					addTerms(string(t.Segment.Value(body)), lineOf(t.Segment.Start))
+				}
+			}
+			return ast.WalkSkipChildren, nil
+		case *ast.FencedCodeBlock, *ast.CodeBlock:
+			lines := n.Lines()
+			for i := 0; i < lines.Len(); i++ {
+				segment := lines.At(i)
+				addTerms(string(segment.Value(body)), lineOf(segment.Start))
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+
+	return doc
+}
+
+// docTags returns the tags of a document: its frontmatter "tags" followed by
+// its #hashtags, lowercased and without duplicates.
+func docTags(meta map[string]any, body []byte) []string {
+	tags := []string{}
+	seen := make(map[string]bool)
+	addTag := func(tag string) {
+		tag = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+		if tag != "" && !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+
+	switch v := meta["tags"].(type) {
+	case string:
+		for _, tag := range strings.Split(v, ",") {
+			addTag(tag)
+		}
+	case []any:
+		for _, tag := range v {
+			if s, ok := tag.(string); ok {
+				addTag(s)
+			}
+		}
+	}
+	for _, tag := range hashtags(body) {
+		addTag(tag)
+	}
+
+	return tags
+}
+
+// searchQuery is a parsed query. Words are ranked with BM25; phrases and
+// field filters must all match.
+type searchQuery struct {
+	words   []string
+	phrases [][]string
+	title   [][]string
+	heading [][]string
+	tags    []string
+	paths   []string
+}
+
+// empty reports whether q has neither terms nor field filters, as for a
+// query made only of punctuation.
+func (q searchQuery) empty() bool {
+	return len(q.words) == 0 && len(q.phrases) == 0 && len(q.title) == 0 && len(q.heading) == 0 && len(q.tags) == 0 && len(q.paths) == 0
+}
+
+// parseSearchQuery splits a query into words, "quoted phrases" and field
+// filters written as field:value or field:"some phrase", where field is
+// title, heading, tag or path.
+func parseSearchQuery(query string) searchQuery {
+	q := searchQuery{}
+	for len(query) > 0 {
+		query = strings.TrimLeft(query, " \t\n")
+		if query == "" {
+			break
+		}
+
+		field := ""
+		if i := strings.IndexByte(query, ':'); i > 0 && !strings.ContainsAny(query[:i], " \t\"") {
+			switch strings.ToLower(query[:i]) {
+			case "title", "heading", "tag", "path":
+				field = strings.ToLower(query[:i])
+				query = query[i+1:]
+			}
+		}
+
+		var value string
+		quoted := strings.HasPrefix(query, "\"")
+		if quoted {
+			end := strings.IndexByte(query[1:], '"')
+			if end < 0 {
+				value, query = query[1:], ""
+			} else {
+				value, query = query[1:end+1], query[end+2:]
+			}
+		} else {
+			end := strings.IndexAny(query, " \t\n")
+			if end < 0 {
+				end = len(query)
+			}
+			value, query = query[:end], query[end:]
+		}
+
+		switch field {
+		case "title":
+			q.title = append(q.title, searchTokens(value))
+		case "heading":
+			q.heading = append(q.heading, searchTokens(value))
+		case "tag":
+			q.tags = append(q.tags, strings.ToLower(strings.TrimPrefix(value, "#")))
+		case "path":
+			q.paths = append(q.paths, strings.ToLower(value))
+		default:
+			tokens := searchTokens(value)
+			if quoted && len(tokens) > 1 {
+				q.phrases = append(q.phrases, tokens)
+			} else {
+				q.words = append(q.words, tokens...)
+			}
+		}
+	}
+	return q
+}
+
+// Search runs query against the index and returns up to limit results,
+// best first.
+func (idx *SearchIndex) Search(query string, limit int) []SearchResult {
+	if limit <= 0 {
+		limit = defaultMaxHits
+	}
+	q := parseSearchQuery(query)
+	if q.empty() {
+		return []SearchResult{}
+	}
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	candidates := make(map[string]bool)
+	if len(q.words) > 0 {
+		for _, word := range q.words {
+			for path := range idx.postings[word] {
+				candidates[path] = true
+			}
+		}
+		for _, phrase := range q.phrases {
+			for path := range idx.postings[phrase[0]] {
+				candidates[path] = true
+			}
+		}
+	} else {
+		for path := range idx.docs {
+			candidates[path] = true
+		}
+	}
+
+	results := []SearchResult{}
+	for path := range candidates {
+		doc := idx.docs[path]
+		matchLines, ok := idx.matchFilters(doc, q)
+		if !ok {
+			continue
+		}
+
+		score := 0.0
+		for _, word := range append(append([]string{}, q.words...), flatten(q.phrases)...) {
+			positions := idx.postings[word][path]
+			if len(positions) == 0 {
+				continue
+			}
+			score += idx.bm25(word, len(positions), len(doc.Terms))
+			for _, pos := range positions {
+				matchLines[doc.TermLine[pos]] = true
+			}
+			if containsToken(searchTokens(doc.Title), word) {
+				score += titleTermBoost
+			}
+			for _, heading := range doc.Headings {
+				if containsToken(searchTokens(heading), word) {
+					score += headingTermBoost
+					break
+				}
+			}
+		}
+
+		results = append(results, SearchResult{
+			Path:     path,
+			Title:    doc.Title,
+			Score:    math.Round(score*1000) / 1000,
+			Snippets: snippets(doc, matchLines),
+		})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Path < results[j].Path
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// matchFilters checks the phrases and field filters of q against doc and
+// returns the lines where phrases matched.
+func (idx *SearchIndex) matchFilters(doc *indexedDoc, q searchQuery) (map[int]bool, bool) {
+	lines := make(map[int]bool)
+
+	for _, phrase := range q.phrases {
+		found := false
+		for _, pos := range idx.postings[phrase[0]][doc.Path] {
+			if hasPhraseAt(doc.Terms, phrase, pos) {
+				lines[doc.TermLine[pos]] = true
+				found = true
+			}
+		}
+		if !found {
+			return nil, false
+		}
+	}
+
+	for _, phrase := range q.title {
+		if !containsPhrase(searchTokens(doc.Title), phrase) {
+			return nil, false
+		}
+	}
+	for _, phrase := range q.heading {
+		found := false
+		for _, heading := range doc.Headings {
+			if containsPhrase(searchTokens(heading), phrase) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, false
+		}
+	}
+	for _, tag := range q.tags {
+		found := false
+		for _, t := range doc.Tags {
+			if t == tag {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, false
+		}
+	}
+	for _, path := range q.paths {
+		if !strings.Contains(strings.ToLower(doc.Path), path) {
+			return nil, false
+		}
+	}
+
+	return lines, true
+}
+
+func (idx *SearchIndex) bm25(term string, tf, docLength int) float64 {
+	n := float64(len(idx.docs))
+	df := float64(len(idx.postings[term]))
+	idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+	avg := float64(idx.length) / math.Max(n, 1)
+	if avg == 0 {
+		avg = 1
+	}
+	f := float64(tf)
+	return idf * f * (bm25K1 + 1) / (f + bm25K1*(1-bm25B+bm25B*float64(docLength)/avg))
+}
+
+func hasPhraseAt(terms, phrase []string, pos int) bool {
+	if pos+len(phrase) > len(terms) {
+		return false
+	}
+	for i, word := range phrase {
+		if terms[pos+i] != word {
+			return false
+		}
+	}
+	return true
+}
+
+func containsPhrase(terms, phrase []string) bool {
+	if len(phrase) == 0 {
+		return true
+	}
+	for pos := range terms {
+		if hasPhraseAt(terms, phrase, pos) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsToken(terms []string, word string) bool {
+	return containsPhrase(terms, []string{word})
+}
+
+func flatten(phrases [][]string) []string {
+	words := []string{}
+	for _, phrase := range phrases {
+		words = append(words, phrase...)
+	}
+	return words
+}
+
+// snippets returns the first matching lines of doc in line order.
+func snippets(doc *indexedDoc, lines map[int]bool) []Snippet {
+	numbers := make([]int, 0, len(lines))
+	for line := range lines {
+		numbers = append(numbers, line)
+	}
+	sort.Ints(numbers)
+	if len(numbers) > maxSnippets {
+		numbers = numbers[:maxSnippets]
+	}
+
+	result := []Snippet{}
+	for _, line := range numbers {
+		if line >= 1 && line <= len(doc.Lines) {
+			result = append(result, Snippet{Line: line, Text: strings.TrimSpace(doc.Lines[line-1])})
+		}
+	}
+	return result
+}
+
+// SearchDocsLogic searches the markdown files under root. The index is built
+// on first use and kept up to date as tools change files.
+func SearchDocsLogic(root, query string, limit int) ([]SearchResult, error) {
+	if parseSearchQuery(query).empty() {
+		return nil, fmt.Errorf("query is empty")
+	}
+	idx, err := searchIndexFor(root)
+	if err != nil {
+		return nil, err
+	}
+	return idx.Search(query, limit), nil
+}
+
+// RebuildIndexLogic reindexes every file under root from scratch.
+func RebuildIndexLogic(root string) (IndexStats, error) {
+	idx, err := searchIndexFor(root)
+	if err != nil {
+		return IndexStats{}, err
+	}
+	if err := idx.Build(); err != nil {
+		return IndexStats{}, err
+	}
+	return idx.Stats(), nil
+}
+
+// IndexStatsLogic reports on the shared search index for root.
+func IndexStatsLogic(root string) (IndexStats, error) {
+	idx, err := searchIndexFor(root)
+	if err != nil {
+		return IndexStats{}, err
+	}
+	return idx.Stats(), nil
+}
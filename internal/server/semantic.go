@@ -0,0 +1,373 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+const (
+	vectorStoreVersion = 1
+	maxExcerptLength   = 300
+)
+
+// SemanticResult is a section of a document that is close in meaning to a
+// query. Line and EndLine delimit the section, numbered from 1.
+type SemanticResult struct {
+	Path    string  `json:"path"`
+	Title   string  `json:"title"`
+	Heading string  `json:"heading,omitempty"`
+	Line    int     `json:"line"`
+	EndLine int     `json:"end_line"`
+	Score   float64 `json:"score"`
+	Text    string  `json:"text"`
+}
+
+// sectionChunk is one section of a document with its embedding. Heading is
+// the trail of headings leading to it, joined with " > ".
+type sectionChunk struct {
+	Heading string    `json:"heading,omitempty"`
+	Line    int       `json:"line"`
+	EndLine int       `json:"end_line"`
+	Text    string    `json:"text"`
+	Vector  []float32 `json:"vector"`
+}
+
+type embeddedDoc struct {
+	ModTime int64          `json:"mod_time"`
+	Hash    string         `json:"hash"`
+	Title   string         `json:"title"`
+	Chunks  []sectionChunk `json:"chunks"`
+}
+
+// persistedVectors is the on-disk form of a semantic index.
+type persistedVectors struct {
+	Version  int                     `json:"version"`
+	Embedder string                  `json:"embedder"`
+	Docs     map[string]*embeddedDoc `json:"docs"`
+}
+
+// SemanticIndex holds the section embeddings of the markdown files under a
+// root folder. Vectors are stored under root/.doc-mcp/vectors, one file per
+// embedder, and only sections of changed files are embedded again.
+type SemanticIndex struct {
+	mu       sync.Mutex
+	root     string
+	embedder Embedder
+	docs     map[string]*embeddedDoc
+	loaded   bool
+}
+
+var (
+	semanticIndexesMu sync.Mutex
+	semanticIndexes   = make(map[string]*SemanticIndex)
+)
+
+// NewSemanticIndex returns an index for the markdown files under root that
+// embeds sections with embedder.
+func NewSemanticIndex(root string, embedder Embedder) *SemanticIndex {
+	return &SemanticIndex{root: root, embedder: embedder, docs: make(map[string]*embeddedDoc)}
+}
+
+// semanticIndexFor returns the shared index for root using the configured
+// embedder.
+func semanticIndexFor(root string) *SemanticIndex {
+	semanticIndexesMu.Lock()
+	defer semanticIndexesMu.Unlock()
+
+	embedder := embedderFor(config.Embedding)
+	if idx, ok := semanticIndexes[root]; ok && idx.embedder.Name() == embedder.Name() {
+		return idx
+	}
+	idx := NewSemanticIndex(root, embedder)
+	semanticIndexes[root] = idx
+	return idx
+}
+
+func (s *SemanticIndex) storeFile() string {
+	return filepath.Join(s.root, stateDir, "vectors", s.embedder.Name()+".json")
+}
+
+// Refresh loads the stored vectors on first use, then embeds the sections of
+// files that are new or changed and forgets files that are gone. It returns
+// the number of documents it embedded.
+func (s *SemanticIndex) Refresh() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.loaded {
+		if err := s.load(); err != nil {
+			return 0, err
+		}
+		s.loaded = true
+	}
+
+	files, err := markdownFilesIn(s.root)
+	if err != nil {
+		return 0, err
+	}
+
+	embedded, changed := 0, false
+	seen := make(map[string]bool)
+	for _, file := range files {
+		rel := rootRelPath(s.root, file)
+		info, err := os.Stat(file)
+		if rel == "" || err != nil {
+			continue
+		}
+		seen[rel] = true
+
+		doc, ok := s.docs[rel]
+		if ok && doc.ModTime == info.ModTime().UnixNano() {
+			continue
+		}
+		source, err := os.ReadFile(file)
+		if err != nil {
+			return embedded, fmt.Errorf("failed to read file %s: %w", file, err)
+		}
+		changed = true
+		if ok && doc.Hash == hashContent(source) {
+			doc.ModTime = info.ModTime().UnixNano()
+			continue
+		}
+
+		doc, err = s.embedDocument(rel, source)
+		if err != nil {
+			return embedded, fmt.Errorf("failed to embed %s: %w", rel, err)
+		}
+		doc.ModTime = info.ModTime().UnixNano()
+		s.docs[rel] = doc
+		embedded++
+	}
+	for rel := range s.docs {
+		if !seen[rel] {
+			delete(s.docs, rel)
+			changed = true
+		}
+	}
+
+	if !changed {
+		return embedded, nil
+	}
+	return embedded, s.save()
+}
+
+func (s *SemanticIndex) load() error {
+	data, err := os.ReadFile(s.storeFile())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read vector store: %w", err)
+	}
+
+	var persisted persistedVectors
+	if json.Unmarshal(data, &persisted) != nil || persisted.Version != vectorStoreVersion || persisted.Embedder != s.embedder.Name() {
+		return nil
+	}
+	if persisted.Docs != nil {
+		s.docs = persisted.Docs
+	}
+	return nil
+}
+
+func (s *SemanticIndex) save() error {
+	data, err := json.Marshal(persistedVectors{Version: vectorStoreVersion, Embedder: s.embedder.Name(), Docs: s.docs})
+	if err != nil {
+		return fmt.Errorf("failed to encode vector store: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.storeFile()), 0755); err != nil {
+		return fmt.Errorf("failed to create vector store folder: %w", err)
+	}
+	if err := os.WriteFile(s.storeFile(), data, 0644); err != nil {
+		return fmt.Errorf("failed to write vector store: %w", err)
+	}
+	return nil
+}
+
+// embedDocument splits a document into sections and embeds them, each
+// prefixed with the document title and its heading trail for context.
+func (s *SemanticIndex) embedDocument(rel string, source []byte) (*embeddedDoc, error) {
+	doc := &embeddedDoc{Hash: hashContent(source), Title: docTitle(source, rel), Chunks: sectionChunks(source)}
+
+	texts := make([]string, len(doc.Chunks))
+	for i, chunk := range doc.Chunks {
+		texts[i] = doc.Title + "\n" + chunk.Heading + "\n" + chunk.Text
+	}
+	vectors, err := s.embedder.Embed(texts)
+	if err != nil {
+		return nil, err
+	}
+	if len(vectors) != len(doc.Chunks) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(doc.Chunks), len(vectors))
+	}
+	for i := range doc.Chunks {
+		doc.Chunks[i].Vector = vectors[i]
+		doc.Chunks[i].Text = excerpt(doc.Chunks[i].Text)
+	}
+	return doc, nil
+}
+
+// sectionChunks splits a markdown document at its headings. Text before the
+// first heading forms its own section; sections with nothing but a heading
+// are dropped.
+func sectionChunks(source []byte) []sectionChunk {
+	_, body := splitFrontmatter(source)
+	lineOffset := frontmatterLines(source, body)
+	lines := strings.Split(string(body), "\n")
+
+	type start struct {
+		line    int
+		heading string
+		titled  bool
+	}
+	starts := []start{{line: 1}}
+	trail := []string{}
+
+	tree := goldmark.New().Parser().Parse(text.NewReader(body))
+	for n := tree.FirstChild(); n != nil; n = n.NextSibling() {
+		heading, ok := n.(*ast.Heading)
+		if !ok || heading.Lines().Len() == 0 {
+			continue
+		}
+		for len(trail) >= heading.Level {
+			trail = trail[:len(trail)-1]
+		}
+		for len(trail) < heading.Level-1 {
+			trail = append(trail, "")
+		}
+		trail = append(trail, plainText(heading, body))
+
+		line := bytes.Count(body[:heading.Lines().At(0).Start], []byte("\n")) + 1
+		if starts[len(starts)-1].line == line {
+			starts = starts[:len(starts)-1]
+		}
+		starts = append(starts, start{line: line, heading: joinTrail(trail), titled: true})
+	}
+
+	chunks := []sectionChunk{}
+	for i, st := range starts {
+		end := len(lines)
+		if i+1 < len(starts) {
+			end = starts[i+1].line - 1
+		}
+		for end >= st.line && strings.TrimSpace(lines[end-1]) == "" {
+			end--
+		}
+		first := st.line
+		if st.titled {
+			first++
+			if first <= end && isSetextUnderline(lines[first-1]) {
+				first++
+			}
+		}
+		if end < first {
+			continue
+		}
+		content := strings.TrimSpace(strings.Join(lines[first-1:end], "\n"))
+		if content == "" {
+			continue
+		}
+		chunks = append(chunks, sectionChunk{
+			Heading: st.heading,
+			Line:    st.line + lineOffset,
+			EndLine: end + lineOffset,
+			Text:    content,
+		})
+	}
+	return chunks
+}
+
+func isSetextUnderline(line string) bool {
+	line = strings.TrimSpace(line)
+	return line != "" && (strings.Trim(line, "=") == "" || strings.Trim(line, "-") == "")
+}
+
+func joinTrail(trail []string) string {
+	parts := []string{}
+	for _, t := range trail {
+		if t != "" {
+			parts = append(parts, t)
+		}
+	}
+	return strings.Join(parts, " > ")
+}
+
+func excerpt(s string) string {
+	runes := []rune(s)
+	if len(runes) <= maxExcerptLength {
+		return s
+	}
+	return strings.TrimSpace(string(runes[:maxExcerptLength])) + "..."
+}
+
+// Search refreshes the index and returns the sections closest to query,
+// best first. limit defaults to 10 when not positive.
+func (s *SemanticIndex) Search(query string, limit int) ([]SemanticResult, error) {
+	if strings.TrimSpace(query) == "" {
+		return nil, fmt.Errorf("query is empty")
+	}
+	if _, err := s.Refresh(); err != nil {
+		return nil, err
+	}
+	vectors, err := s.embedder.Embed([]string{query})
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed query: %w", err)
+	}
+	if len(vectors) != 1 {
+		return nil, fmt.Errorf("expected 1 embedding, got %d", len(vectors))
+	}
+	if limit <= 0 {
+		limit = defaultMaxHits
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	results := []SemanticResult{}
+	for path, doc := range s.docs {
+		for _, chunk := range doc.Chunks {
+			score := cosineSimilarity(vectors[0], chunk.Vector)
+			if score <= 0 {
+				continue
+			}
+			results = append(results, SemanticResult{
+				Path:    path,
+				Title:   doc.Title,
+				Heading: chunk.Heading,
+				Line:    chunk.Line,
+				EndLine: chunk.EndLine,
+				Score:   score,
+				Text:    chunk.Text,
+			})
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		if results[i].Path != results[j].Path {
+			return results[i].Path < results[j].Path
+		}
+		return results[i].Line < results[j].Line
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// SemanticSearchDocsLogic finds the sections of the documents under root
+// closest in meaning to query, using the configured embedder.
+func SemanticSearchDocsLogic(root, query string, limit int) ([]SemanticResult, error) {
+	return semanticIndexFor(root).Search(query, limit)
+}
@@ -0,0 +1,277 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+var stopWords = map[string]bool{
+	"the": true, "and": true, "for": true, "are": true, "but": true, "not": true,
+	"you": true, "all": true, "any": true, "can": true, "has": true, "have": true,
+	"this": true, "that": true, "with": true, "from": true, "into": true, "was": true,
+	"were": true, "will": true, "would": true, "should": true, "could": true, "been": true,
+	"its": true, "our": true, "your": true, "their": true, "there": true, "then": true,
+	"than": true, "when": true, "what": true, "which": true, "who": true, "how": true,
+	"why": true, "also": true, "each": true, "other": true, "some": true, "such": true,
+	"only": true, "more": true, "most": true, "very": true, "just": true, "about": true,
+	"over": true, "under": true, "these": true, "those": true, "them": true, "they": true,
+	"use": true, "used": true, "using": true, "via": true, "per": true, "out": true,
+	"http": true, "https": true, "www": true, "com": true, "md": true,
+}
+
+// tokenize lowercases s and splits it into terms, dropping short words,
+// numbers and stop words.
+func tokenize(s string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	terms := []string{}
+	for _, f := range fields {
+		if len([]rune(f)) < 3 || stopWords[f] || strings.IndexFunc(f, unicode.IsLetter) < 0 {
+			continue
+		}
+		terms = append(terms, f)
+	}
+	return terms
+}
+
+// documentTerms returns the terms of a markdown document. Heading terms are
+// counted twice so that they weigh more than body text.
+func documentTerms(source []byte) []string {
+	doc := goldmark.New().Parser().Parse(text.NewReader(source))
+
+	terms := []string{}
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+
+		switch node := n.(type) {
+		case *ast.Heading:
+			headingTerms := tokenize(string(node.Text(source)))
+			terms = append(terms, headingTerms...)
+			terms = append(terms, headingTerms...)
+			return ast.WalkSkipChildren, nil
+		case *ast.Text:
+			terms = append(terms, tokenize(string(node.Segment.Value(source)))...)
+		case *ast.FencedCodeBlock, *ast.CodeBlock:
+			return ast.WalkSkipChildren, nil
+		}
+
+		return ast.WalkContinue, nil
+	})
+
+	return terms
+}
+
+type termVector map[string]float64
+
+// tfidfVectors builds an L2-normalised TF-IDF vector for every document.
+func tfidfVectors(docs [][]string) []termVector {
+	df := make(map[string]int)
+	for _, terms := range docs {
+		seen := make(map[string]bool)
+		for _, term := range terms {
+			if !seen[term] {
+				seen[term] = true
+				df[term]++
+			}
+		}
+	}
+
+	n := float64(len(docs))
+	vectors := make([]termVector, len(docs))
+	for i, terms := range docs {
+		vec := termVector{}
+		for _, term := range terms {
+			vec[term]++
+		}
+		for term, count := range vec {
+			idf := math.Log((1+n)/(1+float64(df[term]))) + 1
+			vec[term] = count / float64(len(terms)) * idf
+		}
+		vectors[i] = vec.normalize()
+	}
+
+	return vectors
+}
+
+func (v termVector) normalize() termVector {
+	var norm float64
+	for _, w := range v {
+		norm += w * w
+	}
+	if norm == 0 {
+		return v
+	}
+	norm = math.Sqrt(norm)
+	for term, w := range v {
+		v[term] = w / norm
+	}
+	return v
+}
+
+func cosine(a, b termVector) float64 {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	var dot float64
+	for term, w := range a {
+		dot += w * b[term]
+	}
+	return dot
+}
+
+// topTerms returns the n highest weighted terms of v, ties broken alphabetically.
+func (v termVector) topTerms(n int) []string {
+	terms := make([]string, 0, len(v))
+	for term := range v {
+		terms = append(terms, term)
+	}
+	sort.Slice(terms, func(i, j int) bool {
+		if v[terms[i]] != v[terms[j]] {
+			return v[terms[i]] > v[terms[j]]
+		}
+		return terms[i] < terms[j]
+	})
+	if len(terms) > n {
+		terms = terms[:n]
+	}
+	return terms
+}
+
+// kMeans clusters normalised vectors into k groups using cosine similarity.
+// Seeds are chosen deterministically by farthest-point selection so that the
+// same folder always produces the same layout.
+func kMeans(vectors []termVector, k int) []int {
+	assignment := make([]int, len(vectors))
+	if k <= 1 || len(vectors) == 0 {
+		return assignment
+	}
+	if k > len(vectors) {
+		k = len(vectors)
+	}
+
+	centroids := []termVector{vectors[0]}
+	for len(centroids) < k {
+		best, bestSim := -1, math.Inf(1)
+		for i, vec := range vectors {
+			closest := math.Inf(-1)
+			for _, c := range centroids {
+				closest = math.Max(closest, cosine(vec, c))
+			}
+			if closest < bestSim {
+				best, bestSim = i, closest
+			}
+		}
+		centroids = append(centroids, vectors[best])
+	}
+
+	for iter := 0; iter < 50; iter++ {
+		changed := iter == 0
+		for i, vec := range vectors {
+			best, bestSim := 0, math.Inf(-1)
+			for c, centroid := range centroids {
+				if sim := cosine(vec, centroid); sim > bestSim {
+					best, bestSim = c, sim
+				}
+			}
+			if assignment[i] != best {
+				assignment[i] = best
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+
+		for c := range centroids {
+			sum := termVector{}
+			for i, vec := range vectors {
+				if assignment[i] != c {
+					continue
+				}
+				for term, w := range vec {
+					sum[term] += w
+				}
+			}
+			if len(sum) > 0 {
+				centroids[c] = sum.normalize()
+			}
+		}
+	}
+
+	return assignment
+}
+
+// groupBySimilarity clusters markdown files by the TF-IDF similarity of their
+// headings and body text and names each cluster after its dominant terms.
+func groupBySimilarity(folderPath string, files []os.DirEntry, clusters int) (map[string][]os.DirEntry, error) {
+	sorted := append([]os.DirEntry{}, files...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name() < sorted[j].Name() })
+
+	docs := make([][]string, len(sorted))
+	for i, file := range sorted {
+		source, err := os.ReadFile(filepath.Join(folderPath, file.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file %s: %w", file.Name(), err)
+		}
+		docs[i] = documentTerms(source)
+	}
+
+	if clusters <= 0 {
+		clusters = (len(sorted) + maxFolderItems - 1) / maxFolderItems
+		if clusters < 2 {
+			clusters = 2
+		}
+	}
+
+	vectors := tfidfVectors(docs)
+	assignment := kMeans(vectors, clusters)
+
+	members := make(map[int][]int)
+	for i, c := range assignment {
+		members[c] = append(members[c], i)
+	}
+
+	clusterIDs := make([]int, 0, len(members))
+	for c := range members {
+		clusterIDs = append(clusterIDs, c)
+	}
+	sort.Ints(clusterIDs)
+
+	groups := make(map[string][]os.DirEntry)
+	for _, c := range clusterIDs {
+		weights := termVector{}
+		for _, i := range members[c] {
+			for term, w := range vectors[i] {
+				weights[term] += w
+			}
+		}
+
+		name := strings.Join(weights.topTerms(2), "-")
+		if name == "" {
+			name = fmt.Sprintf("cluster%d", c+1)
+		}
+		unique := name
+		for n := 2; groups[unique] != nil; n++ {
+			unique = fmt.Sprintf("%s%d", name, n)
+		}
+
+		for _, i := range members[c] {
+			groups[unique] = append(groups[unique], sorted[i])
+		}
+	}
+
+	return groups, nil
+}
@@ -0,0 +1,168 @@
+package server
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/yuin/goldmark/text"
+)
+
+const (
+	termWeight          = 0.6
+	tagWeight           = 0.25
+	folderWeight        = 0.15
+	defaultMaxLinkHints = 5
+)
+
+// LinkCandidate is a document worth linking to, with a relative markdown link
+// ready to insert into the source document.
+type LinkCandidate struct {
+	Path        string   `json:"path"`
+	Title       string   `json:"title"`
+	Score       float64  `json:"score"`
+	SharedTerms []string `json:"shared_terms"`
+	SharedTags  []string `json:"shared_tags"`
+	Markdown    string   `json:"markdown"`
+}
+
+// SuggestLinksLogic ranks the documents under root that the document at
+// path should link to, by term overlap, shared tags and folder proximity.
+// When content is empty the document is read from path; otherwise path is
+// only where the content lives or will live and decides the relative links.
+// Documents already linked are left out. limit defaults to 5 when not
+// positive.
+func SuggestLinksLogic(root, docPath, content string, limit int) ([]LinkCandidate, error) {
+	if limit <= 0 {
+		limit = defaultMaxLinkHints
+	}
+	if docPath == "" {
+		if content == "" {
+			return nil, fmt.Errorf("path or content is required")
+		}
+		docPath = "untitled.md"
+	}
+	file, err := resolveInRoot(root, docPath)
+	if err != nil {
+		return nil, err
+	}
+	if content == "" {
+		source, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file %s: %w", docPath, err)
+		}
+		content = string(source)
+	}
+	self := rootRelPath(root, file)
+
+	g, err := linkGraphFor(root)
+	if err != nil {
+		return nil, err
+	}
+	g.mu.RLock()
+	docs := g.sortedDocs()
+	g.mu.RUnlock()
+
+	source := []byte(content)
+	meta, body := splitFrontmatter(source)
+	tags := docTags(meta, body)
+
+	linked := make(map[string]bool)
+	tree := newMarkdownParser().Parser().Parse(text.NewReader(source))
+	for _, dest := range relativeMarkdownLinks(tree, filepath.Dir(file)) {
+		linked[rootRelPath(root, dest)] = true
+	}
+	resolver := newWikiResolver()
+	for _, doc := range docs {
+		resolver.add(doc.Path, doc.Title, doc.Path)
+	}
+	for _, link := range wikiLinksIn(tree) {
+		linked[resolver.resolve(string(link.Target))] = true
+	}
+
+	candidates := []*graphDoc{}
+	terms := [][]string{documentTerms(body)}
+	for _, doc := range docs {
+		if doc.Path == self || linked[doc.Path] {
+			continue
+		}
+		candidates = append(candidates, doc)
+		terms = append(terms, doc.Terms)
+	}
+	vectors := tfidfVectors(terms)
+
+	fromDir := path.Dir(self)
+	suggestions := []LinkCandidate{}
+	for i, doc := range candidates {
+		termScore := cosine(vectors[0], vectors[i+1])
+		shared := intersectStrings(tags, doc.Tags)
+		if termScore <= 0 && len(shared) == 0 {
+			continue
+		}
+		tagScore := 0.0
+		if union := len(tags) + len(doc.Tags) - len(shared); union > 0 {
+			tagScore = float64(len(shared)) / float64(union)
+		}
+		score := termWeight*termScore + tagWeight*tagScore + folderWeight*folderProximity(fromDir, path.Dir(doc.Path))
+
+		rel, err := filepath.Rel(filepath.FromSlash(fromDir), filepath.FromSlash(doc.Path))
+		if err != nil {
+			continue
+		}
+		suggestions = append(suggestions, LinkCandidate{
+			Path:        doc.Path,
+			Title:       doc.Title,
+			Score:       roundScore(score),
+			SharedTerms: sharedTerms(vectors[0], vectors[i+1], maxSharedTerms),
+			SharedTags:  shared,
+			Markdown:    fmt.Sprintf("[%s](%s)", escapeLinkText(doc.Title), escapeLinkDestination(filepath.ToSlash(rel))),
+		})
+	}
+
+	sort.SliceStable(suggestions, func(i, j int) bool { return suggestions[i].Score > suggestions[j].Score })
+	if len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+	return suggestions, nil
+}
+
+// folderProximity is 1 for documents in the same folder and falls with the
+// number of folders between them.
+func folderProximity(a, b string) float64 {
+	split := func(dir string) []string {
+		if dir == "." || dir == "" {
+			return nil
+		}
+		return strings.Split(dir, "/")
+	}
+	pa, pb := split(a), split(b)
+	common := 0
+	for common < len(pa) && common < len(pb) && pa[common] == pb[common] {
+		common++
+	}
+	return 1 / float64(1+len(pa)+len(pb)-2*common)
+}
+
+func intersectStrings(a, b []string) []string {
+	shared := []string{}
+	for _, s := range a {
+		if containsString(b, s) {
+			shared = append(shared, s)
+		}
+	}
+	return shared
+}
+
+func escapeLinkText(s string) string {
+	return strings.NewReplacer("[", `\[`, "]", `\]`).Replace(s)
+}
+
+// escapeLinkDestination percent-encodes the slash-separated path rel so that
+// spaces, parentheses and the like keep a link destination intact.
+func escapeLinkDestination(rel string) string {
+	return (&url.URL{Path: rel}).EscapedPath()
+}
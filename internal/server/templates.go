@@ -0,0 +1,432 @@
+package server
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// templatesFolderName is the folder under the doc root holding document
+// templates. Its files are not documents: they are left out of searches,
+// the link graph, indexes and refactors.
+const templatesFolderName = "templates"
+
+const defaultTemplateName = "standard"
+
+// maxRelatedLinks is how many related documents a template gets in .Related.
+const maxRelatedLinks = 5
+
+// standardTemplate is used when the templates folder has no standard.md.
+const standardTemplate = `{{- /* The standard skeleton: frontmatter, Overview, Details and Related links. */ -}}
+{{- /* Title: the document title; defaults to the file name. */ -}}
+{{- /* Summary: a sentence or two on what the document covers, for the Overview. */ -}}
+{{- /* Details: the body of the Details section. */ -}}
+{{- /* Tags: comma-separated tags for the frontmatter. */ -}}
+---
+title: {{yaml .Title}}
+author: {{yaml .Author}}
+date: {{.Date}}
+{{- if .Tags}}
+tags: [{{.Tags}}]
+{{- end}}
+---
+
+# {{.Title}}
+
+## Overview
+
+{{if .Summary}}{{.Summary}}{{else}}TODO: what this document covers and why it matters.{{end}}
+
+## Details
+
+{{if .Details}}{{.Details}}{{else}}TODO: the details.{{end}}
+
+## Related
+
+{{range .Related}}- {{.Markdown}}
+{{else}}- TODO: link related documents.
+{{end -}}
+`
+
+// templateFuncs are available to every template: yaml quotes a value as a
+// YAML string for the frontmatter.
+var templateFuncs = template.FuncMap{"yaml": yamlQuote}
+
+// autoVariables are filled in for every template: Title from the file name
+// unless given, Date as YYYY-MM-DD, Author from the git settings, and
+// Related as suggested links with .Path, .Title and .Markdown.
+var autoVariables = map[string]string{
+	"Title":   "the document title; defaults to the file name",
+	"Date":    "today's date as YYYY-MM-DD",
+	"Author":  "the configured git author, else the local git user",
+	"Related": "suggested links to related documents, each with .Path, .Title and .Markdown",
+}
+
+// TemplateVariable is a variable a template uses. Auto variables are filled
+// in when not given; Required ones must be given.
+type TemplateVariable struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required"`
+	Auto        bool   `json:"auto"`
+}
+
+// TemplateInfo describes an available template. Path is empty for the
+// built-in standard template.
+type TemplateInfo struct {
+	Name        string             `json:"name"`
+	Path        string             `json:"path,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Variables   []TemplateVariable `json:"variables"`
+}
+
+// ListTemplatesLogic lists the templates in the templates folder under root,
+// and the built-in standard template unless the folder overrides it.
+func ListTemplatesLogic(root string) ([]TemplateInfo, error) {
+	names := map[string]bool{defaultTemplateName: true}
+	entries, err := os.ReadDir(filepath.Join(root, templatesFolderName))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read templates: %w", err)
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".md") && !strings.HasPrefix(entry.Name(), ".") {
+			names[strings.TrimSuffix(entry.Name(), ".md")] = true
+		}
+	}
+
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	templates := []TemplateInfo{}
+	for _, name := range sorted {
+		info, _, err := loadTemplate(root, name)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, *info)
+	}
+	return templates, nil
+}
+
+// loadTemplate reads the template called name and derives its variables.
+func loadTemplate(root, name string) (*TemplateInfo, string, error) {
+	if name == "" {
+		name = defaultTemplateName
+	}
+	if name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return nil, "", fmt.Errorf("invalid template name %q", name)
+	}
+
+	info := &TemplateInfo{Name: name}
+	file := filepath.Join(root, templatesFolderName, name+".md")
+	source, err := os.ReadFile(file)
+	switch {
+	case err == nil:
+		info.Path = rootRelPath(root, file)
+	case os.IsNotExist(err) && name == defaultTemplateName:
+		source = []byte(standardTemplate)
+	case os.IsNotExist(err):
+		return nil, "", fmt.Errorf("no template %s in %s", name, filepath.Join(root, templatesFolderName))
+	default:
+		return nil, "", fmt.Errorf("failed to read template %s: %w", name, err)
+	}
+
+	if err := describeTemplate(info, string(source)); err != nil {
+		return nil, "", fmt.Errorf("failed to parse template %s: %w", name, err)
+	}
+	return info, string(source), nil
+}
+
+// describeTemplate derives the variables of a template from its parse
+// tree. A variable is optional when it is only tested by if, with or range,
+// or used with a fallback; comments such as {{/* Name: what it is */}}
+// describe variables, and the first other comment describes the template.
+func describeTemplate(info *TemplateInfo, source string) error {
+	tree := parse.New(info.Name)
+	tree.Mode = parse.ParseComments | parse.SkipFuncCheck
+	trees := make(map[string]*parse.Tree)
+	if _, err := tree.Parse(source, "", "", trees); err != nil {
+		return err
+	}
+
+	used := []string{}
+	optional := make(map[string]bool)
+	seen := make(map[string]bool)
+	comments := []string{}
+	use := func(name string, tested bool) {
+		if !seen[name] {
+			seen[name] = true
+			used = append(used, name)
+		}
+		if tested {
+			optional[name] = true
+		}
+	}
+
+	// walk visits a node; rebound is set once dot no longer is the data,
+	// inside range and with, and tested within their conditions.
+	var walk func(n parse.Node, rebound, tested bool)
+	walk = func(n parse.Node, rebound, tested bool) {
+		switch node := n.(type) {
+		case *parse.ListNode:
+			if node == nil {
+				return
+			}
+			for _, child := range node.Nodes {
+				walk(child, rebound, tested)
+			}
+		case *parse.CommentNode:
+			comments = append(comments, strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(node.Text, "/*"), "*/")))
+		case *parse.ActionNode:
+			walk(node.Pipe, rebound, tested)
+		case *parse.IfNode:
+			walk(node.Pipe, rebound, true)
+			walk(node.List, rebound, tested)
+			walk(node.ElseList, rebound, tested)
+		case *parse.RangeNode:
+			walk(node.Pipe, rebound, true)
+			walk(node.List, true, tested)
+			walk(node.ElseList, rebound, tested)
+		case *parse.WithNode:
+			walk(node.Pipe, rebound, true)
+			walk(node.List, true, tested)
+			walk(node.ElseList, rebound, tested)
+		case *parse.TemplateNode:
+			walk(node.Pipe, rebound, tested)
+		case *parse.PipeNode:
+			if node == nil {
+				return
+			}
+			for _, cmd := range node.Cmds {
+				walk(cmd, rebound, tested)
+			}
+		case *parse.CommandNode:
+			// Anything after the first argument of a command, as in
+			// {{or .Owner "nobody"}}, comes with a fallback.
+			for i, arg := range node.Args {
+				walk(arg, rebound, tested || (i > 0 && isFallback(node)))
+			}
+		case *parse.FieldNode:
+			if !rebound {
+				use(node.Ident[0], tested)
+			}
+		case *parse.VariableNode:
+			if len(node.Ident) > 1 && node.Ident[0] == "$" {
+				use(node.Ident[1], tested)
+			}
+		case *parse.ChainNode:
+			walk(node.Node, rebound, tested)
+		}
+	}
+	for _, t := range trees {
+		walk(t.Root, false, false)
+	}
+
+	descriptions := make(map[string]string)
+	for _, comment := range comments {
+		name, description, found := strings.Cut(comment, ":")
+		if found && seen[strings.TrimSpace(name)] {
+			descriptions[strings.TrimSpace(name)] = strings.TrimSpace(description)
+		} else if info.Description == "" {
+			info.Description = comment
+		}
+	}
+
+	info.Variables = []TemplateVariable{}
+	for _, name := range used {
+		variable := TemplateVariable{Name: name, Description: descriptions[name]}
+		if description, ok := autoVariables[name]; ok {
+			variable.Auto = true
+			if variable.Description == "" {
+				variable.Description = description
+			}
+		} else {
+			variable.Required = !optional[name]
+		}
+		info.Variables = append(info.Variables, variable)
+	}
+	return nil
+}
+
+// isFallback reports whether a command picks among its arguments, so that
+// the later ones stand in for an empty first one.
+func isFallback(cmd *parse.CommandNode) bool {
+	ident, ok := cmd.Args[0].(*parse.IdentifierNode)
+	return ok && (ident.Ident == "or" || ident.Ident == "and")
+}
+
+// CreateFromTemplateLogic renders the template called name into a new
+// document at docPath, both relative to root, and returns its content.
+// vars sets the template's variables; the auto variables are filled in
+// unless given, and every required variable must be given.
+func CreateFromTemplateLogic(root, name, docPath string, vars map[string]string) (string, error) {
+	file, err := resolveInRoot(root, docPath)
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasSuffix(docPath, ".md") {
+		return "", fmt.Errorf("%s is not a markdown file", docPath)
+	}
+	if isTemplatePath(rootRelPath(root, file)) {
+		return "", fmt.Errorf("%s is inside the templates folder", docPath)
+	}
+	if _, err := os.Stat(file); err == nil {
+		return "", fmt.Errorf("%s already exists", docPath)
+	}
+
+	info, source, err := loadTemplate(root, name)
+	if err != nil {
+		return "", err
+	}
+
+	data := make(map[string]any)
+	missing := []string{}
+	for _, variable := range info.Variables {
+		value, given := vars[variable.Name]
+		if variable.Required && strings.TrimSpace(value) == "" {
+			missing = append(missing, variable.Name)
+		}
+		if given {
+			data[variable.Name] = value
+		} else {
+			data[variable.Name] = ""
+		}
+	}
+	if len(missing) > 0 {
+		return "", fmt.Errorf("template %s needs %s", info.Name, strings.Join(missing, ", "))
+	}
+	for given := range vars {
+		if _, ok := data[given]; !ok {
+			return "", fmt.Errorf("template %s has no variable %s", info.Name, given)
+		}
+	}
+	fillAutoVariables(root, docPath, data, vars)
+
+	tmpl, err := template.New(info.Name).Funcs(templateFuncs).Parse(source)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse template %s: %w", info.Name, err)
+	}
+	render := func() (string, error) {
+		var out strings.Builder
+		if err := tmpl.Execute(&out, data); err != nil {
+			return "", fmt.Errorf("failed to render template %s: %w", info.Name, err)
+		}
+		return out.String(), nil
+	}
+
+	content, err := render()
+	if err != nil {
+		return "", err
+	}
+	// Related links are suggested from a first rendering, so they match
+	// what the document says.
+	if _, ok := vars["Related"]; !ok {
+		related, err := SuggestLinksLogic(root, docPath, content, maxRelatedLinks)
+		if err == nil && len(related) > 0 {
+			data["Related"] = related
+			if content, err = render(); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return "", fmt.Errorf("failed to create directory %s: %w", filepath.Dir(file), err)
+	}
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", docPath, err)
+	}
+	return content, nil
+}
+
+// fillAutoVariables sets the auto variables that were not given.
+func fillAutoVariables(root, docPath string, data map[string]any, vars map[string]string) {
+	if _, ok := vars["Title"]; !ok {
+		title := strings.TrimSuffix(filepath.Base(docPath), ".md")
+		title = strings.Join(strings.Fields(strings.NewReplacer("-", " ", "_", " ").Replace(title)), " ")
+		if first, size := utf8.DecodeRuneInString(title); first != utf8.RuneError {
+			title = string(unicode.ToUpper(first)) + title[size:]
+		}
+		data["Title"] = title
+	}
+	if _, ok := vars["Date"]; !ok {
+		data["Date"] = time.Now().Format("2006-01-02")
+	}
+	if _, ok := vars["Author"]; !ok {
+		data["Author"] = templateAuthor(root)
+	}
+	if _, ok := vars["Related"]; !ok {
+		data["Related"] = []LinkCandidate{}
+	}
+}
+
+// yamlQuote returns s as a double-quoted YAML scalar, escaping the
+// characters YAML does not allow there unescaped.
+func yamlQuote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range strings.ToValidUTF8(s, string(utf8.RuneError)) {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case unicode.IsPrint(r):
+			b.WriteRune(r)
+		case r > 0xFFFF:
+			fmt.Fprintf(&b, `\U%08X`, r)
+		default:
+			fmt.Fprintf(&b, `\u%04X`, r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// templateAuthor is the configured git author, else the git user of the
+// repository holding root, else the login name.
+func templateAuthor(root string) string {
+	if config.Git.AuthorName != "" {
+		return config.Git.AuthorName
+	}
+	if dir, err := filepath.Abs(root); err == nil {
+		for ; ; dir = filepath.Dir(dir) {
+			if _, err := os.Stat(dir); err == nil {
+				break
+			}
+		}
+		if name, err := git(dir, "config", "user.name"); err == nil && name != "" {
+			return name
+		}
+	}
+	return os.Getenv("USER")
+}
+
+// isTemplatesFolder reports whether folder is the templates folder of root.
+func isTemplatesFolder(root, folder string) bool {
+	abs, err := filepath.Abs(folder)
+	if err != nil {
+		return false
+	}
+	templates, err := filepath.Abs(filepath.Join(root, templatesFolderName))
+	return err == nil && abs == templates
+}
+
+// isTemplatePath reports whether rel, a slash-separated path relative to
+// the doc root, lies in the templates folder.
+func isTemplatePath(rel string) bool {
+	return rel == templatesFolderName || strings.HasPrefix(rel, templatesFolderName+"/")
+}
@@ -0,0 +1,248 @@
+package server
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+const (
+	tocEndMarker    = "<!-- doc-mcp:toc:end -->"
+	defaultTOCDepth = 3
+	// minTOCSections is how many sections a document needs before the
+	// automatic TOC inserts one.
+	minTOCSections = 3
+)
+
+var tocStartRe = regexp.MustCompile(`^<!-- doc-mcp:toc:start(?: depth=(-?\d+))? -->$`)
+
+// TOCOptions configures automatic tables of contents. With Auto set, the
+// TOC of every document written by create, edit and edit_section is
+// refreshed, and one is inserted into documents with at least 3 sections.
+// Depth is the deepest heading level listed in inserted TOCs, 3 when zero.
+type TOCOptions struct {
+	Auto  bool
+	Depth int
+}
+
+// tocBlock locates the TOC of a document: the offsets of its start marker
+// and past its end marker line, and the depth recorded in the start marker.
+type tocBlock struct {
+	start, stop int
+	depth       int
+}
+
+// findTOC looks for the markers in the top-level HTML blocks of source, so
+// that markers quoted in code are left alone. A depth below 1 in the start
+// marker is read as the default depth.
+func findTOC(source string) (tocBlock, bool) {
+	src := []byte(source)
+	doc := goldmark.New().Parser().Parse(text.NewReader(src))
+
+	block := tocBlock{start: -1}
+	for n := doc.FirstChild(); n != nil; n = n.NextSibling() {
+		html, ok := n.(*ast.HTMLBlock)
+		if !ok || html.Lines().Len() == 0 {
+			continue
+		}
+		line := html.Lines().At(0)
+		marker := strings.TrimSpace(string(line.Value(src)))
+
+		if block.start < 0 {
+			if m := tocStartRe.FindStringSubmatch(marker); m != nil {
+				block.start = line.Start
+				block.depth = defaultTOCDepth
+				if depth, err := strconv.Atoi(m[1]); err == nil && depth > 0 {
+					block.depth = depth
+				}
+			}
+			continue
+		}
+		if marker == tocEndMarker {
+			block.stop = line.Stop
+			return block, true
+		}
+	}
+	return tocBlock{}, false
+}
+
+// buildTOC lists the headings of source down to level depth as nested links
+// to their anchors. A leading level 1 heading is the document's title and
+// is left out.
+func buildTOC(source []byte, depth int) string {
+	_, body := splitFrontmatter(source)
+	anchors := documentAnchors(goldmark.New().Parser().Parse(text.NewReader(body)), body)
+	if len(anchors) > 0 && anchors[0].heading.Level == 1 {
+		anchors = anchors[1:]
+	}
+
+	top := 0
+	for _, a := range anchors {
+		if a.heading.Level <= depth && (top == 0 || a.heading.Level < top) {
+			top = a.heading.Level
+		}
+	}
+
+	var toc strings.Builder
+	for _, a := range anchors {
+		if a.heading.Level > depth {
+			continue
+		}
+		fmt.Fprintf(&toc, "%s- [%s](#%s)\n", strings.Repeat("  ", a.heading.Level-top), escapeLinkText(a.text), a.anchor)
+	}
+	return toc.String()
+}
+
+// countSections returns the number of headings below the title of source.
+func countSections(source []byte) int {
+	_, body := splitFrontmatter(source)
+	anchors := documentAnchors(goldmark.New().Parser().Parse(text.NewReader(body)), body)
+	if len(anchors) > 0 && anchors[0].heading.Level == 1 {
+		return len(anchors) - 1
+	}
+	return len(anchors)
+}
+
+// updateTOC refreshes the TOC between the markers of source. depth, when
+// positive, replaces the depth recorded in the start marker. Without
+// markers a TOC is inserted after the title if insert is set. It reports
+// whether source changed.
+func updateTOC(source string, depth int, insert bool) (string, bool) {
+	block, found := findTOC(source)
+	if !found && !insert {
+		return source, false
+	}
+	if depth <= 0 {
+		depth = defaultTOCDepth
+		if found {
+			depth = block.depth
+		}
+	}
+
+	// Headings are read with the old TOC left out, so its own text never
+	// counts.
+	without := source
+	if found {
+		without = source[:block.start] + source[block.stop:]
+	}
+	toc := fmt.Sprintf("<!-- doc-mcp:toc:start depth=%d -->\n%s%s\n", depth, buildTOC([]byte(without), depth), tocEndMarker)
+
+	var updated string
+	if found {
+		updated = source[:block.start] + toc + source[block.stop:]
+	} else {
+		at := tocInsertOffset([]byte(source))
+		updated = source[:at] + "\n" + toc
+		if rest := source[at:]; rest != "" && !strings.HasPrefix(rest, "\n") {
+			updated += "\n"
+		}
+		updated += source[at:]
+	}
+	return updated, updated != source
+}
+
+// tocInsertOffset is where a new TOC goes: after the title heading when the
+// document starts with one, else at the start of the body.
+func tocInsertOffset(source []byte) int {
+	_, body := splitFrontmatter(source)
+	offset := len(source) - len(body)
+	doc := goldmark.New().Parser().Parse(text.NewReader(body))
+	if heading, ok := doc.FirstChild().(*ast.Heading); ok && heading.Level == 1 {
+		if _, stop, ok := headingBounds(heading, body); ok {
+			return offset + stop
+		}
+	}
+	return offset
+}
+
+// staleTOC reports whether content has a TOC that no longer matches its
+// headings.
+func staleTOC(content string) bool {
+	if _, found := findTOC(content); !found {
+		return false
+	}
+	_, changed := updateTOC(content, 0, false)
+	return changed
+}
+
+// autoTOC applies the automatic TOC policy of the server config to content.
+func autoTOC(content string) string {
+	if !config.TOC.Auto {
+		return content
+	}
+	if _, found := findTOC(content); found {
+		content, _ = updateTOC(content, 0, false)
+		return content
+	}
+	if countSections([]byte(content)) >= minTOCSections {
+		content, _ = updateTOC(content, config.TOC.Depth, true)
+	}
+	return content
+}
+
+// autoTOCFile applies the automatic TOC policy to the markdown file at path.
+func autoTOCFile(path string) error {
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read file %s: %w", path, err)
+	}
+	content := autoTOC(string(source))
+	if content == string(source) {
+		return nil
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// UpdateTOCLogic refreshes the tables of contents of the markdown files at
+// path under root, a file or a folder, or of every file when path is empty.
+// A file given by path gets a TOC inserted if it has none; in folders only
+// existing TOCs are refreshed. depth, when positive, sets the deepest heading
+// level listed. It returns the files that changed.
+func UpdateTOCLogic(root, path string, depth int) ([]string, error) {
+	target := root
+	if path != "" {
+		var err error
+		if target, err = resolveInRoot(root, path); err != nil {
+			return nil, err
+		}
+	}
+	info, err := os.Stat(target)
+	if err != nil {
+		return nil, fmt.Errorf("%s not found: %w", path, err)
+	}
+
+	files := []string{target}
+	if info.IsDir() {
+		if files, err = markdownFilesIn(target); err != nil {
+			return nil, err
+		}
+	} else if !strings.HasSuffix(target, ".md") {
+		return nil, fmt.Errorf("%s is not a markdown file", path)
+	}
+
+	updated := []string{}
+	for _, file := range files {
+		source, err := os.ReadFile(file)
+		if err != nil {
+			return updated, fmt.Errorf("failed to read file %s: %w", file, err)
+		}
+		content, changed := updateTOC(string(source), depth, !info.IsDir())
+		if !changed {
+			continue
+		}
+		if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+			return updated, fmt.Errorf("failed to write %s: %w", file, err)
+		}
+		updated = append(updated, rootRelPath(root, file))
+	}
+	return updated, nil
+}
@@ -17,4 +17,129 @@ type ValidateMarkdownParams struct {
 
 type RefactorFolderParams struct {
 	FolderPath string `json:"folder_path,omitempty"`
-} 
\ No newline at end of file
+	Strategy   string `json:"strategy,omitempty"`
+	Clusters   int    `json:"clusters,omitempty"`
+	GroupKey   string `json:"group_key,omitempty"`
+	Indexes    bool   `json:"indexes,omitempty"`
+}
+
+type GenerateIndexesParams struct {
+	FolderPath string `json:"folder_path,omitempty"`
+}
+
+type MoveMarkdownFileParams struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type DeleteMarkdownFileParams struct {
+	Path        string `json:"path"`
+	Force       bool   `json:"force,omitempty"`
+	Replacement string `json:"replacement,omitempty"`
+	StripLinks  bool   `json:"strip_links,omitempty"`
+}
+
+type EditSectionParams struct {
+	Path       string `json:"path"`
+	Heading    string `json:"heading"`
+	NewHeading string `json:"new_heading,omitempty"`
+	Content    string `json:"content,omitempty"`
+}
+
+type ConvertWikiLinksParams struct {
+	Path string `json:"path,omitempty"`
+}
+
+type UpdateTOCParams struct {
+	Path  string `json:"path,omitempty"`
+	Depth int    `json:"depth,omitempty"`
+}
+
+type CreateFromTemplateParams struct {
+	Template  string            `json:"template,omitempty"`
+	Path      string            `json:"path"`
+	Variables map[string]string `json:"variables,omitempty"`
+}
+
+type ListTemplatesParams struct{}
+
+type StoreAssetParams struct {
+	Doc       string `json:"doc"`
+	Name      string `json:"name"`
+	Data      string `json:"data"`
+	NextToDoc bool   `json:"next_to_doc,omitempty"`
+	Overwrite bool   `json:"overwrite,omitempty"`
+}
+
+type AssetReportParams struct{}
+
+type UndoLastOperationParams struct {
+	Force bool `json:"force,omitempty"`
+}
+
+type ListOperationsParams struct {
+	Limit int `json:"limit,omitempty"`
+}
+
+type DocHistoryParams struct {
+	Path  string `json:"path"`
+	Limit int    `json:"limit,omitempty"`
+}
+
+type DocDiffParams struct {
+	Path string `json:"path"`
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+}
+
+type DocBlameParams struct {
+	Path string `json:"path"`
+}
+
+type SearchDocsParams struct {
+	Query string `json:"query"`
+	Limit int    `json:"limit,omitempty"`
+}
+
+type GetBacklinksParams struct {
+	Path string `json:"path"`
+}
+
+type GetOutgoingLinksParams struct {
+	Path string `json:"path"`
+}
+
+type GetNeighborsParams struct {
+	Path  string `json:"path"`
+	Depth int    `json:"depth,omitempty"`
+}
+
+type SuggestLinksParams struct {
+	Path    string `json:"path,omitempty"`
+	Content string `json:"content,omitempty"`
+	Limit   int    `json:"limit,omitempty"`
+}
+
+type LinkReportParams struct{}
+
+type ExportGraphParams struct {
+	Format  string `json:"format,omitempty"`
+	Cluster bool   `json:"cluster,omitempty"`
+	Path    string `json:"path,omitempty"`
+	Tag     string `json:"tag,omitempty"`
+}
+
+type FindDuplicatesParams struct {
+	Path      string  `json:"path,omitempty"`
+	Content   string  `json:"content,omitempty"`
+	Threshold float64 `json:"threshold,omitempty"`
+}
+
+type SemanticSearchDocsParams struct {
+	Query string `json:"query"`
+	Limit int    `json:"limit,omitempty"`
+}
+
+type IndexStatsParams struct{}
+
+type RebuildIndexParams struct{}
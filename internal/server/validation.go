@@ -1,17 +1,55 @@
 package server
 
 import (
-	"regexp"
+	"fmt"
+	"sort"
 	"strings"
+
+	"github.com/yuin/goldmark/ast"
 )
 
 func validateMarkdown(content string) []string {
 	warnings := []string{}
 
-	linkRe := regexp.MustCompile(`\[[^\]]+\]\(([^)]+)\)`)
-	links := linkRe.FindAllStringSubmatch(content, -1)
-	if len(links) < 2 {
-		warnings = append(warnings, "File should have at least 2 internal links.")
+	// Counting parsed links takes in reference links such as [text][ref],
+	// whose destination is in a definition elsewhere in the file. The links
+	// of a table of contents are left out, here and in the anchor check: a
+	// stale one gets a warning of its own.
+	counted := content
+	if toc, found := findTOC(content); found {
+		counted = content[:toc.start] + content[toc.stop:]
+	}
+	doc, links := parseLinks(newMarkdownParser(), []byte(counted))
+	count := 0
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if entering && (n.Kind() == ast.KindLink || n.Kind() == KindWikiLink) {
+			count++
+		}
+		return ast.WalkContinue, nil
+	})
+	if count < 2 {
+		warnings = append(warnings, "File should have at least 2 internal links. Use suggest_links to find related documents.")
+	}
+
+	used := make(map[string]bool)
+	for _, span := range links.spans {
+		used[span.label] = true
+	}
+	unused := []linkDefinition{}
+	for label, def := range links.definitions {
+		if !used[label] {
+			unused = append(unused, def)
+		}
+	}
+	sort.Slice(unused, func(i, j int) bool { return unused[i].start < unused[j].start })
+	for _, def := range unused {
+		warnings = append(warnings, fmt.Sprintf("Link definition [%s]: %s is not used by any link.", def.label, def.dest))
+	}
+
+	warnings = append(warnings, validateOwnAnchors(counted)...)
+
+	if staleTOC(content) {
+		warnings = append(warnings, "Table of contents is out of date. Use update_toc to refresh it.")
 	}
 
 	lines := strings.Split(content, "\n")
@@ -0,0 +1,378 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// KindWikiLink is the node kind of wiki links.
+var KindWikiLink = ast.NewNodeKind("WikiLink")
+
+// WikiLink is an Obsidian-style [[Target]] or [[Target|alias]] link. Target
+// may name a document by title, file name or path relative to the doc root,
+// with an optional #heading. Its child is the text shown for it, and Segment
+// covers the whole link in the source so that it can be rewritten in place.
+type WikiLink struct {
+	ast.BaseInline
+	Target  []byte
+	Alias   []byte
+	Segment text.Segment
+}
+
+func (n *WikiLink) Kind() ast.NodeKind {
+	return KindWikiLink
+}
+
+func (n *WikiLink) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Target": string(n.Target), "Alias": string(n.Alias)}, nil)
+}
+
+type wikiLinkParser struct{}
+
+func (p *wikiLinkParser) Trigger() []byte {
+	return []byte{'['}
+}
+
+func (p *wikiLinkParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, segment := block.PeekLine()
+	if !bytes.HasPrefix(line, []byte("[[")) {
+		return nil
+	}
+	end := bytes.Index(line[2:], []byte("]]"))
+	if end < 0 {
+		return nil
+	}
+	inner := line[2 : 2+end]
+	if len(bytes.TrimSpace(inner)) == 0 || bytes.ContainsAny(inner, "[]\n") {
+		return nil
+	}
+
+	start := segment.Start
+	target, alias, hasAlias := bytes.Cut(inner, []byte("|"))
+	node := &WikiLink{
+		Target:  bytes.TrimSpace(target),
+		Segment: text.NewSegment(start, start+2+end+2),
+	}
+	label := text.NewSegment(start+2, start+2+len(target))
+	if hasAlias {
+		node.Alias = bytes.TrimSpace(alias)
+		label = text.NewSegment(start+2+len(target)+1, start+2+end)
+	}
+	node.AppendChild(node, ast.NewTextSegment(label))
+
+	block.Advance(2 + end + 2)
+	return node
+}
+
+type wikiLinkExtension struct{}
+
+// WikiLinks is a goldmark extension that parses [[wiki links]].
+var WikiLinks goldmark.Extender = &wikiLinkExtension{}
+
+func (e *wikiLinkExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(parser.WithInlineParsers(util.Prioritized(&wikiLinkParser{}, 199)))
+}
+
+// newMarkdownParser returns the parser used to read documents, with wiki
+// link support.
+func newMarkdownParser() goldmark.Markdown {
+	return goldmark.New(goldmark.WithExtensions(WikiLinks))
+}
+
+// wikiLinksIn returns the wiki links of a parsed document in source order.
+func wikiLinksIn(doc ast.Node) []*WikiLink {
+	links := []*WikiLink{}
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if link, ok := n.(*WikiLink); ok && entering {
+			links = append(links, link)
+		}
+		return ast.WalkContinue, nil
+	})
+	return links
+}
+
+// splitWikiTarget separates the page of a wiki link target from its
+// #heading.
+func splitWikiTarget(target string) (string, string) {
+	page, heading, _ := strings.Cut(target, "#")
+	return strings.TrimSpace(page), strings.TrimSpace(heading)
+}
+
+// wikiResolver finds the document a wiki link target names: first by path
+// relative to the root, then by file name, then by title, all without case.
+// Names shared by several documents resolve to the first path alphabetically.
+type wikiResolver struct {
+	paths  map[string]string
+	stems  map[string][]string
+	titles map[string][]string
+}
+
+func newWikiResolver() *wikiResolver {
+	return &wikiResolver{paths: make(map[string]string), stems: make(map[string][]string), titles: make(map[string][]string)}
+}
+
+func wikiKey(s string) string {
+	s = strings.TrimPrefix(filepath.ToSlash(strings.TrimSpace(s)), "/")
+	return strings.ToLower(strings.TrimSuffix(s, ".md"))
+}
+
+// add registers the document at rel, relative to the root, under its path,
+// file name and title. value is what resolve returns for it.
+func (r *wikiResolver) add(rel, title, value string) {
+	r.paths[wikiKey(rel)] = value
+	stem := wikiKey(filepath.Base(rel))
+	r.stems[stem] = append(r.stems[stem], value)
+	if title != "" {
+		r.titles[wikiKey(title)] = append(r.titles[wikiKey(title)], value)
+	}
+}
+
+// resolve returns the value of the document target names, or an empty
+// string.
+func (r *wikiResolver) resolve(target string) string {
+	page, _ := splitWikiTarget(target)
+	key := wikiKey(page)
+	if key == "" {
+		return ""
+	}
+	if value, ok := r.paths[key]; ok {
+		return value
+	}
+	for _, candidates := range [][]string{r.stems[key], r.titles[key]} {
+		if len(candidates) > 0 {
+			sorted := append([]string{}, candidates...)
+			sort.Strings(sorted)
+			return sorted[0]
+		}
+	}
+	return ""
+}
+
+// unique reports whether name is the file name of a single document.
+func (r *wikiResolver) unique(name string) bool {
+	return len(r.stems[wikiKey(name)]) == 1
+}
+
+// wikiResolverFor indexes the markdown files under root. original maps the
+// current absolute path of files to the one they are known by, so that links
+// can be resolved as they were before a move; skip lists absolute paths to
+// leave out. Values are absolute paths.
+func wikiResolverFor(root string, original map[string]string, skip map[string]string) (*wikiResolver, error) {
+	files, err := markdownFilesIn(root)
+	if err != nil {
+		return nil, err
+	}
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+
+	r := newWikiResolver()
+	for _, file := range files {
+		abs, err := filepath.Abs(file)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := skip[abs]; ok {
+			continue
+		}
+		source, err := os.ReadFile(abs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file %s: %w", file, err)
+		}
+		known := abs
+		if o, ok := original[abs]; ok {
+			known = o
+		}
+		rel, err := filepath.Rel(absRoot, known)
+		if err != nil {
+			continue
+		}
+		r.add(filepath.ToSlash(rel), docTitle(source, ""), known)
+	}
+	return r, nil
+}
+
+// sourceEdit replaces source[start:stop] with text.
+type sourceEdit struct {
+	start, stop int
+	text        string
+}
+
+// applyEdits splices non-overlapping edits into source.
+func applyEdits(source []byte, edits []sourceEdit) []byte {
+	sort.Slice(edits, func(i, j int) bool { return edits[i].start < edits[j].start })
+	var out bytes.Buffer
+	last := 0
+	for _, edit := range edits {
+		out.Write(source[last:edit.start])
+		out.WriteString(edit.text)
+		last = edit.stop
+	}
+	out.Write(source[last:])
+	return out.Bytes()
+}
+
+// formatWikiLink writes a wiki link back with a new page, keeping its
+// heading and alias.
+func formatWikiLink(link *WikiLink, page string) string {
+	_, heading := splitWikiTarget(string(link.Target))
+	target := page
+	if heading != "" {
+		target += "#" + heading
+	}
+	if link.Alias != nil {
+		return "[[" + target + "|" + string(link.Alias) + "]]"
+	}
+	return "[[" + target + "]]"
+}
+
+// wikiName returns the shortest name that makes newResolver resolve to abs:
+// its file name when that is unambiguous, otherwise its path relative to
+// root, both without the .md extension.
+func wikiName(root, abs string, newResolver *wikiResolver) string {
+	stem := strings.TrimSuffix(filepath.Base(abs), ".md")
+	if newResolver.unique(stem) && newResolver.resolve(stem) == abs {
+		return stem
+	}
+	absRoot, _ := filepath.Abs(root)
+	rel, err := filepath.Rel(absRoot, abs)
+	if err != nil {
+		return stem
+	}
+	return strings.TrimSuffix(filepath.ToSlash(rel), ".md")
+}
+
+// rewriteWikiLinks updates the wiki links in source that would no longer
+// reach their document. oldResolver resolves links as they were written,
+// to original absolute paths; target maps those to the absolute path the
+// link should reach now; newResolver resolves against the tree as it is
+// after the change.
+func rewriteWikiLinks(root string, source []byte, oldResolver, newResolver *wikiResolver, target func(string) string) ([]byte, bool) {
+	doc := newMarkdownParser().Parser().Parse(text.NewReader(source))
+	edits := []sourceEdit{}
+	for _, link := range wikiLinksIn(doc) {
+		old := oldResolver.resolve(string(link.Target))
+		if old == "" {
+			continue
+		}
+		want := target(old)
+		if newResolver.resolve(string(link.Target)) == want {
+			continue
+		}
+		edits = append(edits, sourceEdit{
+			start: link.Segment.Start,
+			stop:  link.Segment.Stop,
+			text:  formatWikiLink(link, wikiName(root, want, newResolver)),
+		})
+	}
+	if len(edits) == 0 {
+		return source, false
+	}
+	return applyEdits(source, edits), true
+}
+
+// headingSlug returns the GitHub-style anchor of a heading: lowercase, with
+// spaces turned into hyphens and punctuation other than hyphens and
+// underscores removed.
+func headingSlug(heading string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(strings.TrimSpace(heading)) {
+		switch {
+		case unicode.IsLetter(r), unicode.IsDigit(r), r == '-', r == '_':
+			b.WriteRune(r)
+		case r == ' ':
+			b.WriteRune('-')
+		}
+	}
+	return b.String()
+}
+
+// ConvertWikiLinksLogic replaces the wiki links in the markdown files at
+// path under root, a file or folder, with standard relative links. It
+// returns the files it rewrote and the wiki links it could not resolve,
+// which are left as they are.
+func ConvertWikiLinksLogic(root, path string) ([]string, []string, error) {
+	target := root
+	if path != "" {
+		var err error
+		if target, err = resolveInRoot(root, path); err != nil {
+			return nil, nil, err
+		}
+	}
+	if _, err := os.Stat(target); err != nil {
+		return nil, nil, fmt.Errorf("%s not found: %w", path, err)
+	}
+	files, err := targetFiles(target)
+	if err != nil {
+		return nil, nil, err
+	}
+	resolver, err := wikiResolverFor(root, nil, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	paths := make([]string, 0, len(files))
+	for file := range files {
+		paths = append(paths, file)
+	}
+	sort.Strings(paths)
+
+	converted, unresolved := []string{}, []string{}
+	for _, file := range paths {
+		source, err := os.ReadFile(file)
+		if err != nil {
+			return converted, unresolved, fmt.Errorf("failed to read file %s: %w", file, err)
+		}
+
+		doc := newMarkdownParser().Parser().Parse(text.NewReader(source))
+		edits := []sourceEdit{}
+		for _, link := range wikiLinksIn(doc) {
+			page, heading := splitWikiTarget(string(link.Target))
+			href := ""
+			// [[#Heading]] points into the document itself.
+			if page != "" || heading == "" {
+				dest := resolver.resolve(string(link.Target))
+				if dest == "" {
+					unresolved = append(unresolved, fmt.Sprintf("%s: [[%s]]", file, link.Target))
+					continue
+				}
+				rel, err := filepath.Rel(filepath.Dir(file), dest)
+				if err != nil {
+					continue
+				}
+				href = escapeLinkDestination(filepath.ToSlash(rel))
+			}
+			if heading != "" {
+				href += "#" + headingSlug(heading)
+			}
+			label := page
+			if page == "" {
+				label = heading
+			}
+			if link.Alias != nil {
+				label = string(link.Alias)
+			}
+			edits = append(edits, sourceEdit{start: link.Segment.Start, stop: link.Segment.Stop, text: fmt.Sprintf("[%s](%s)", escapeLinkText(label), href)})
+		}
+		if len(edits) == 0 {
+			continue
+		}
+		if err := os.WriteFile(file, applyEdits(source, edits), 0644); err != nil {
+			return converted, unresolved, fmt.Errorf("failed to write %s: %w", file, err)
+		}
+		converted = append(converted, file)
+	}
+	return converted, unresolved, nil
+}
@@ -3,18 +3,31 @@ package main
 import (
 	"context"
 	"log"
+	"os"
 
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 	"github.com/shardqa/doc-mcp/internal/server"
 )
 
 func main() {
+	server.SetConfig(server.ConfigFromEnv())
+
+	if len(os.Args) > 1 && isCommand(os.Args[1]) {
+		os.Exit(runCommand(os.Args[1:], os.Stdout, os.Stderr))
+	}
+
+	go func() {
+		if err := server.LoadSearchIndex("doc"); err != nil {
+			log.Printf("failed to load search index: %v", err)
+		}
+	}()
+
 	srv := mcp.NewServer("doc_mcp", "0.1.0", nil)
 
 	srv.AddTools(
 		mcp.NewServerTool(
 			"create_markdown_file",
-			"Create a new markdown file. Parameters: name (string, required) is the file name, content (string, required) is the markdown content, path (string, optional) is a relative folder path inside the project where the file will be created. If path is omitted, the file is created in the current directory.",
+			"Create a new markdown file. Parameters: name (string, required) is the file name, content (string, required) is the markdown content, path (string, optional) is a relative folder path inside the project where the file will be created. If path is omitted, the file is created in the current directory. When auto refactor is enabled and the folder ends up with more than 10 items, the result includes the refactor plan or the refactor that was applied, and reports where the new file ended up. If the content substantially overlaps existing documents in doc/, the result warns about them so you can edit or merge instead; the file is still created.",
 			server.CreateMarkdownFile,
 		),
 		mcp.NewServerTool(
@@ -29,9 +42,184 @@ func main() {
 		),
 		mcp.NewServerTool(
 			"refactor_folder",
-			"Refactor a folder by creating subdirectories and moving files. Parameters: folder_path (string, optional) is the relative path to the folder to refactor. Defaults to doc/.",
+			"Refactor a folder by creating subdirectories and moving files. Works recursively until no folder in the tree holds more than 10 items (files or subfolders), nesting groups into an extra level of folders when needed. Images and attachments referenced only by documents that move go with them, and assets folders do not count as items. Parameters: folder_path (string, optional) is the relative path to the folder to refactor, which must lie inside doc/. Defaults to doc/. strategy (string, optional) is how files are grouped: \"prefix\" (default) groups by the name part before \"_\" or \"-\", \"semantic\" clusters files by TF-IDF similarity of their headings and text and names each folder after its dominant terms, \"tag\" groups files by a frontmatter key or their most frequent #hashtag and uses the prefix rule for untagged files. clusters (integer, optional) is the number of folders the semantic strategy aims for; defaults to one per 10 files. group_key (string, optional) is the frontmatter key used by the tag strategy; defaults to \"category\". indexes (boolean, optional) regenerates the index.md of every folder after refactoring.",
 			server.RefactorFolder,
 		),
+		mcp.NewServerTool(
+			"generate_indexes",
+			"Write or update an index.md in a folder and all its subfolders, listing subfolders and documents with their titles and first-paragraph summaries. Only the block between the doc-mcp index markers is regenerated; hand-written text around it is kept. Parameters: folder_path (string, optional) is the relative path to the folder, which must lie inside doc/. Defaults to doc/.",
+			server.GenerateIndexes,
+		),
+		mcp.NewServerTool(
+			"move_markdown_file",
+			"Move or rename a markdown file or folder inside doc/ and rewrite relative links and images across the knowledge base, both links pointing at the moved documents and links inside them. Images and attachments in the document's folder or its assets folder that only moved documents reference move along with them. Parameters: from (string, required) is the current path relative to doc/, to (string, required) is the new path relative to doc/. If to is an existing folder, the source is moved into it.",
+			server.MoveMarkdownFile,
+		),
+		mcp.NewServerTool(
+			"delete_markdown_file",
+			"Delete a markdown file or folder inside doc/ by moving it to the recoverable trash folder doc/.doc-mcp/trash/. If other documents link to it, the deletion is refused and the referrers are listed, unless one of the options is given. Parameters: path (string, required) is the path relative to doc/, force (boolean, optional) deletes anyway and leaves the links broken, replacement (string, optional) is a doc path relative to doc/ that inbound links are rewritten to, strip_links (boolean, optional) replaces inbound links with their plain text.",
+			server.DeleteMarkdownFile,
+		),
+		mcp.NewServerTool(
+			"edit_section",
+			"Edit one section of a markdown file in doc/ without rewriting the whole file. The section runs from its heading to the next heading of the same or a higher level. Renaming a heading rewrites every link to its old anchor across doc/, such as guide.md#old-name and [[Guide#Old Name]], so they keep working. Parameters: path (string, required) is the file path relative to doc/, heading (string, required) is the current heading text or its anchor, new_heading (string, optional) renames the heading, content (string, optional) replaces the body of the section; at least one of new_heading and content is required.",
+			server.EditSection,
+		),
+		mcp.NewServerTool(
+			"convert_wiki_links",
+			"Convert Obsidian-style wiki links ([[Page]], [[Page|alias]], [[Page#Heading]]) into standard relative markdown links such as [alias](../guides/page.md#heading). Targets are resolved across doc/ by path, file name or title. Wiki links are otherwise fully supported: they count as internal links, appear in the link graph and are rewritten when files move, so converting is optional. Unresolved wiki links are left as they are and reported. Parameters: path (string, optional) is a file or folder relative to doc/ to convert; if omitted every file in doc/ is converted.",
+			server.ConvertWikiLinks,
+		),
+		mcp.NewServerTool(
+			"update_toc",
+			"Insert or refresh the table of contents of markdown files in doc/. The TOC is a nested list of links to the document's headings, below its title, kept between <!-- doc-mcp:toc:start --> and <!-- doc-mcp:toc:end --> markers; only that block is rewritten. validate_markdown_file warns when a TOC no longer matches the headings, and with DOC_MCP_TOC_AUTO=true create, edit and edit_section refresh TOCs on their own and add one to documents with at least 3 sections. Parameters: path (string, optional) is a file or folder relative to doc/; a file gets a TOC inserted if it has none, while in folders only existing TOCs are refreshed; defaults to all of doc/, depth (integer, optional) is the deepest heading level listed, recorded in the start marker; defaults to the recorded depth or 3.",
+			server.UpdateTOC,
+		),
+		mcp.NewServerTool(
+			"create_from_template",
+			"Create a markdown file in doc/ from a template, so that new documents follow the standard skeleton: frontmatter, Overview, Details and Related sections. Templates are Go text/template files in doc/templates/, named after the file without .md; a built-in standard template is used unless doc/templates/standard.md overrides it. Title (from the file name), Date, Author (from the git settings) and Related (suggested links to related documents) are filled in when not given. The file is validated like create_markdown_file. Parameters: path (string, required) is the new file's path relative to doc/; the file must not exist, template (string, optional) is the template name; defaults to standard, variables (object, optional) maps variable names to string values; list_templates shows which a template needs.",
+			server.CreateFromTemplate,
+		),
+		mcp.NewServerTool(
+			"list_templates",
+			"List the document templates available to create_from_template, with their description and variables: each variable's name, description, whether it is required and whether it is filled in automatically. Returns JSON. Takes no parameters.",
+			server.ListTemplates,
+		),
+		mcp.NewServerTool(
+			"store_asset",
+			"Store an image or attachment for a document in doc/, by default in the assets folder next to the document. Returns the stored path and a ready-to-insert image or link relative to the document, such as ![diagram](assets/diagram.png). Referenced assets move with their documents when files are moved or folders refactored, and their links are rewritten. Parameters: doc (string, required) is the document path relative to doc/; it does not have to exist yet, name (string, required) is the asset file name, data (string, required) is the file content encoded in base64, a data: URL is accepted too, next_to_doc (boolean, optional) stores the asset in the document's folder instead of its assets folder, overwrite (boolean, optional) replaces an existing asset of the same name.",
+			server.StoreAsset,
+		),
+		mcp.NewServerTool(
+			"asset_report",
+			"Report on the images and attachments in doc/: files other than markdown documents that no document references, and images or links to local files that do not exist, with the document and line they appear on. Returns JSON. Takes no parameters.",
+			server.GetAssetReport,
+		),
+		mcp.NewServerTool(
+			"undo_last_operation",
+			"Revert the most recent mutating tool call (create, edit, refactor, generate indexes, move or delete) by restoring every file it touched from the journal in doc/.doc-mcp/journal. Refuses if those files changed since, unless forced. Parameters: force (boolean, optional) restores the files even if they were changed afterwards.",
+			server.UndoLastOperation,
+		),
+		mcp.NewServerTool(
+			"list_operations",
+			"List the journaled mutating tool calls that can be undone, oldest first, as JSON with the tool, its arguments and the files touched with before/after content hashes. Parameters: limit (integer, optional) returns only the most recent operations.",
+			server.ListOperations,
+		),
+		mcp.NewServerTool(
+			"doc_history",
+			"List the git commits that changed a document, newest first and following renames, as JSON with hash, author, email, date and subject. Parameters: path (string, required) is the document path relative to doc/, limit (integer, optional) is the maximum number of commits.",
+			server.DocHistory,
+		),
+		mcp.NewServerTool(
+			"doc_diff",
+			"Diff a document between two git revisions, or between a revision and the working tree, as JSON hunks with numbered context, added and removed lines. Parameters: path (string, required) is the document path relative to doc/, from (string, optional) is the base revision and defaults to HEAD, to (string, optional) is the target revision; if omitted the working tree is used.",
+			server.DocDiff,
+		),
+		mcp.NewServerTool(
+			"doc_blame",
+			"Show, for every line of a document, the git commit that last changed it, as JSON with line number, hash, author, email, date, commit summary and text. Parameters: path (string, required) is the document path relative to doc/.",
+			server.DocBlame,
+		),
+		mcp.NewServerTool(
+			"search_docs",
+			"Full-text search over the markdown files in doc/, ranked with BM25. Use it to find existing knowledge before creating a new file. Returns JSON results with path, title, score and matching lines with line numbers. Parameters: query (string, required) is made of words, \"quoted phrases\" and field filters title:, heading:, tag: and path: (for example title:\"getting started\" tag:api path:guides), limit (integer, optional) is the maximum number of results and defaults to 10.",
+			server.SearchDocs,
+		),
+		mcp.NewServerTool(
+			"get_backlinks",
+			"List the documents in doc/ that link to a document, so you can see where it is referenced before changing or moving it. Returns JSON with, for every link, the linking document's path and title, the link text, its line number in the linking document and the line it appears on as context. Parameters: path (string, required) is the document path relative to doc/.",
+			server.GetBacklinks,
+		),
+		mcp.NewServerTool(
+			"get_outgoing_links",
+			"List the internal links written in a document. Returns JSON with, for every link, the target path relative to doc/ and its title, the link text, the line number and the line it appears on as context; links to documents that do not exist are marked missing. Parameters: path (string, required) is the document path relative to doc/.",
+			server.GetOutgoingLinks,
+		),
+		mcp.NewServerTool(
+			"get_neighbors",
+			"Explore the knowledge graph around a document: every document reachable within a number of links, following links in both directions. Returns JSON with the nodes (path, title and distance in links) and the links between them (source, target and link text). Parameters: path (string, required) is the document path relative to doc/, depth (integer, optional) is the maximum number of links to follow and defaults to 1.",
+			server.GetNeighbors,
+		),
+		mcp.NewServerTool(
+			"suggest_links",
+			"Suggest existing documents in doc/ to link to, for example when validation warns that a file should have at least 2 internal links. Documents are ranked by shared terms, shared tags and how close their folders are; documents already linked are left out. Returns JSON with path, title, score, shared terms and tags, and markdown: a ready-to-insert link such as [Title](../guides/setup.md), relative to the document's location. Parameters: path (string, optional) is the document path relative to doc/, content (string, optional) is markdown to find links for instead of the saved file, in which case path is where it will be saved and defaults to doc/ itself; one of path or content is required, limit (integer, optional) is the maximum number of suggestions and defaults to 5.",
+			server.SuggestLinks,
+		),
+		mcp.NewServerTool(
+			"link_report",
+			"Report documents in doc/ that are hard to reach or lead nowhere: orphans that no other document links to, dead ends that link to no other document, and the disconnected groups of documents when the knowledge base is not one connected graph. Each orphan and dead end comes with up to 3 suggested documents to link from or to, chosen by shared terms, with their similarity score and the shared terms. Returns JSON. Takes no parameters. The same report is available from the command line with doc-mcp link-report.",
+			server.GetLinkReport,
+		),
+		mcp.NewServerTool(
+			"export_graph",
+			"Export the link graph of the documents in doc/ for visualisation, as Graphviz DOT, a Mermaid flowchart or JSON nodes and edges. Nodes are documents labelled with their titles and edges are the internal links between them. Parameters: format (string, optional) is \"json\" (default), \"dot\" or \"mermaid\", cluster (boolean, optional) groups documents by folder into clusters or subgraphs, path (string, optional) limits the graph to a folder relative to doc/, tag (string, optional) limits the graph to documents with that frontmatter tag or hashtag. Links to documents left out by the filters are dropped. The same export is available from the command line with doc-mcp export-graph.",
+			server.ExportGraph,
+		),
+		mcp.NewServerTool(
+			"find_duplicates",
+			"Find markdown documents in doc/ that duplicate each other, comparing overlapping five-word shingles with MinHash. Use it to spot documents that should be merged, or pass content to check a draft before creating a new file; create_markdown_file runs the same check and warns when the new file overlaps existing ones. Returns JSON: without content, pairs of documents with their estimated similarity and overlap (share of the smaller document found in the other); with content, the existing documents it overlaps. Parameters: path (string, optional) limits the comparison to a folder relative to doc/, content (string, optional) is a draft to check against every document, threshold (number, optional) is the minimum overlap between 0 and 1 and defaults to 0.5.",
+			server.FindDuplicates,
+		),
+		mcp.NewServerTool(
+			"semantic_search_docs",
+			"Search the markdown files in doc/ by meaning rather than exact words, so paraphrased content is found too. Documents are split into sections at their headings and each section is embedded; vectors are kept under doc/.doc-mcp/vectors and only changed files are embedded again. Embeddings are computed offline from hashed words and character n-grams unless DOC_MCP_EMBEDDING_URL points at an OpenAI-compatible embeddings endpoint. Returns JSON results with path, title, heading trail, start and end line, similarity score and an excerpt of the section. Parameters: query (string, required) is a natural language description of what you are looking for, limit (integer, optional) is the maximum number of sections and defaults to 10.",
+			server.SemanticSearchDocs,
+		),
+		mcp.NewServerTool(
+			"index_stats",
+			"Report on the search index kept under doc/.doc-mcp/index: number of documents, distinct terms and tokens, how many files the last load or rebuild reused or reindexed and how many it dropped, and when it was last saved. Returns JSON. Takes no parameters.",
+			server.GetIndexStats,
+		),
+		mcp.NewServerTool(
+			"rebuild_index",
+			"Rebuild the search index from scratch, reindexing every markdown file in doc/ and saving the result. The index normally stays up to date on its own, reindexing only changed files on startup and after every tool call, so use this only when it looks stale or corrupt. Returns the index stats as JSON. Takes no parameters.",
+			server.RebuildIndex,
+		),
+	)
+
+	srv.AddPrompts(
+		&mcp.ServerPrompt{
+			Prompt: &mcp.Prompt{
+				Name:        "document_feature",
+				Description: "Write a new document for a feature from the standard template, with the documents that already mention it attached so that it is linked to them rather than documented twice.",
+				Arguments: []*mcp.PromptArgument{
+					{Name: "feature", Description: "The feature to document.", Required: true},
+					{Name: "folder", Description: "The folder relative to doc/ to put the document in; defaults to doc/."},
+				},
+			},
+			Handler: server.DocumentFeaturePrompt,
+		},
+		&mcp.ServerPrompt{
+			Prompt: &mcp.Prompt{
+				Name:        "summarize_folder",
+				Description: "Summarize the documents of a folder, with the documents and their current validation warnings attached.",
+				Arguments: []*mcp.PromptArgument{
+					{Name: "folder", Description: "The folder relative to doc/; defaults to doc/."},
+				},
+			},
+			Handler: server.SummarizeFolderPrompt,
+		},
+		&mcp.ServerPrompt{
+			Prompt: &mcp.Prompt{
+				Name:        "fix_validation_warnings",
+				Description: "Fix the validation warnings of a document or folder, with the current warnings and the files that have them attached.",
+				Arguments: []*mcp.PromptArgument{
+					{Name: "path", Description: "A file or folder relative to doc/; defaults to doc/."},
+				},
+			},
+			Handler: server.FixValidationWarningsPrompt,
+		},
+		&mcp.ServerPrompt{
+			Prompt: &mcp.Prompt{
+				Name:        "write_adr",
+				Description: "Record an architecture decision as the next numbered ADR in doc/adr/, with the latest records attached.",
+				Arguments: []*mcp.PromptArgument{
+					{Name: "title", Description: "The decision to record.", Required: true},
+					{Name: "context", Description: "Background on the problem and the options considered."},
+				},
+			},
+			Handler: server.WriteADRPrompt,
+		},
 	)
 
 	if err := srv.Run(context.Background(), mcp.NewStdioTransport()); err != nil {
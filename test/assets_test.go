@@ -0,0 +1,93 @@
+package test
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/shardqa/doc-mcp/internal/server"
+	"github.com/stretchr/testify/require"
+)
+
+var assetTree = map[string]string{
+	"guide.md":           "# Guide\n\n![Diagram](assets/diagram.png)\n\nSee [the logo](logo.svg) and [the spec](assets/spec%20v2.pdf).\n",
+	"home.md":            "# Home\n\n![](logo.svg)\n\nBroken ![chart](assets/chart.png).\n",
+	"assets/diagram.png": "png",
+	"assets/spec v2.pdf": "pdf",
+	"assets/unused.png":  "png",
+	"logo.svg":           "svg",
+}
+
+func TestStoreAssetLogic(t *testing.T) {
+	root := t.TempDir()
+	data := base64.StdEncoding.EncodeToString([]byte("image bytes"))
+
+	asset, err := server.StoreAssetLogic(root, "guides/setup.md", "flow chart.png", "data:image/png;base64,"+data, server.StoreAssetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, "guides/assets/flow chart.png", asset.Path)
+	require.Equal(t, 11, asset.Size)
+	require.Equal(t, "![flow chart](assets/flow%20chart.png)", asset.Markdown)
+
+	content, err := os.ReadFile(filepath.Join(root, "guides", "assets", "flow chart.png"))
+	require.NoError(t, err)
+	require.Equal(t, "image bytes", string(content))
+
+	_, err = server.StoreAssetLogic(root, "guides/setup.md", "flow chart.png", data, server.StoreAssetOptions{})
+	require.Error(t, err)
+
+	asset, err = server.StoreAssetLogic(root, "guides/setup.md", "notes.txt", data, server.StoreAssetOptions{NextToDoc: true})
+	require.NoError(t, err)
+	require.Equal(t, "guides/notes.txt", asset.Path)
+	require.Equal(t, "[notes](notes.txt)", asset.Markdown)
+
+	_, err = server.StoreAssetLogic(root, "guides/setup.md", "../escape.png", data, server.StoreAssetOptions{})
+	require.Error(t, err)
+	_, err = server.StoreAssetLogic(root, "guides/setup.md", "bad.png", "not base64!", server.StoreAssetOptions{})
+	require.Error(t, err)
+
+	huge := base64.StdEncoding.EncodeToString(make([]byte, 10<<20+1))
+	_, err = server.StoreAssetLogic(root, "guides/setup.md", "huge.png", huge, server.StoreAssetOptions{})
+	require.ErrorContains(t, err, "larger than")
+	require.NoFileExists(t, filepath.Join(root, "guides", "assets", "huge.png"))
+}
+
+func TestMoveMarkdownFile_CarriesAssets(t *testing.T) {
+	root := writeTree(t, assetTree)
+
+	_, _, err := server.MoveMarkdownFileLogic(root, "guide.md", "guides/guide.md")
+	require.NoError(t, err)
+
+	guide, err := os.ReadFile(filepath.Join(root, "guides", "guide.md"))
+	require.NoError(t, err)
+	require.Equal(t, "# Guide\n\n![Diagram](assets/diagram.png)\n\nSee [the logo](../logo.svg) and [the spec](assets/spec%20v2.pdf).\n", string(guide))
+
+	require.FileExists(t, filepath.Join(root, "guides", "assets", "diagram.png"))
+	require.FileExists(t, filepath.Join(root, "guides", "assets", "spec v2.pdf"))
+	require.FileExists(t, filepath.Join(root, "assets", "unused.png"))
+	require.FileExists(t, filepath.Join(root, "logo.svg"))
+}
+
+func TestMoveMarkdownFile_RewritesImagesToMovedFolder(t *testing.T) {
+	root := writeTree(t, assetTree)
+
+	_, _, err := server.MoveMarkdownFileLogic(root, "assets", "media")
+	require.NoError(t, err)
+
+	guide, err := os.ReadFile(filepath.Join(root, "guide.md"))
+	require.NoError(t, err)
+	require.Equal(t, "# Guide\n\n![Diagram](media/diagram.png)\n\nSee [the logo](logo.svg) and [the spec](media/spec%20v2.pdf).\n", string(guide))
+}
+
+func TestAssetReportLogic(t *testing.T) {
+	root := writeTree(t, assetTree)
+
+	report, err := server.AssetReportLogic(root)
+	require.NoError(t, err)
+	require.Equal(t, 4, report.Assets)
+	require.Equal(t, []string{"assets/unused.png"}, report.Unused)
+	require.Len(t, report.Missing, 1)
+	require.Equal(t, "home.md", report.Missing[0].Document)
+	require.Equal(t, 5, report.Missing[0].Line)
+	require.Equal(t, "assets/chart.png", report.Missing[0].Destination)
+}
@@ -0,0 +1,119 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/shardqa/doc-mcp/internal/server"
+	"github.com/stretchr/testify/require"
+)
+
+func writeNumberedFiles(t *testing.T, dir string, count int) {
+	for i := 0; i < count; i++ {
+		prefix := "alpha"
+		if i%2 == 1 {
+			prefix = "beta"
+		}
+		name := fmt.Sprintf("%s_%d.md", prefix, i)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("# Doc\n"), 0644))
+	}
+}
+
+func TestCheckFolderLimit_WithinLimit(t *testing.T) {
+	tempDir := t.TempDir()
+	writeNumberedFiles(t, tempDir, 10)
+
+	suggestion, err := server.CheckFolderLimitLogic(tempDir, tempDir, server.AutoRefactorSuggest, server.RefactorOptions{})
+	require.NoError(t, err)
+	require.Nil(t, suggestion)
+}
+
+func TestCheckFolderLimit_Suggest(t *testing.T) {
+	tempDir := t.TempDir()
+	writeNumberedFiles(t, tempDir, 12)
+
+	suggestion, err := server.CheckFolderLimitLogic(tempDir, tempDir, server.AutoRefactorSuggest, server.RefactorOptions{})
+	require.NoError(t, err)
+	require.NotNil(t, suggestion)
+	require.False(t, suggestion.Applied)
+	require.Equal(t, 12, suggestion.Items)
+	require.Len(t, suggestion.Moves, 12)
+	require.Equal(t, filepath.Join(tempDir, "alpha", "alpha_0.md"), suggestion.Moves[0].To)
+
+	entries, err := os.ReadDir(tempDir)
+	require.NoError(t, err)
+	require.Len(t, entries, 12)
+}
+
+func TestCheckFolderLimit_Apply(t *testing.T) {
+	tempDir := t.TempDir()
+	writeNumberedFiles(t, tempDir, 12)
+
+	suggestion, err := server.CheckFolderLimitLogic(tempDir, tempDir, server.AutoRefactorApply, server.RefactorOptions{})
+	require.NoError(t, err)
+	require.NotNil(t, suggestion)
+	require.True(t, suggestion.Applied)
+	require.FileExists(t, filepath.Join(tempDir, "alpha", "alpha_0.md"))
+	require.FileExists(t, filepath.Join(tempDir, "beta", "beta_1.md"))
+	require.Equal(t, filepath.Join(tempDir, "alpha", "alpha_0.md"), suggestion.Moved[filepath.Join(tempDir, "alpha_0.md")])
+}
+
+func TestCheckFolderLimit_Off(t *testing.T) {
+	tempDir := t.TempDir()
+	writeNumberedFiles(t, tempDir, 12)
+
+	suggestion, err := server.CheckFolderLimitLogic(tempDir, tempDir, server.AutoRefactorOff, server.RefactorOptions{})
+	require.NoError(t, err)
+	require.Nil(t, suggestion)
+}
+
+func TestCheckFolderLimit_NoRoomForGroups(t *testing.T) {
+	tempDir := t.TempDir()
+	writeNumberedFiles(t, tempDir, 3)
+	for i := 0; i < 9; i++ {
+		require.NoError(t, os.WriteFile(filepath.Join(tempDir, fmt.Sprintf("asset_%d.png", i)), []byte("png"), 0644))
+	}
+
+	_, err := server.CheckFolderLimitLogic(tempDir, tempDir, server.AutoRefactorSuggest, server.RefactorOptions{})
+	require.Error(t, err)
+}
+
+func TestCreateMarkdownFile_AutoRefactorStaysInDoc(t *testing.T) {
+	root := t.TempDir()
+	t.Chdir(root)
+	require.NoError(t, os.MkdirAll(filepath.Join("doc", "guides"), 0755))
+	for i := 0; i < 11; i++ {
+		require.NoError(t, os.MkdirAll(fmt.Sprintf("pkg%d", i), 0755))
+	}
+	writeNumberedFiles(t, filepath.Join(root, "doc", "guides"), 11)
+	server.SetConfig(server.Config{AutoRefactor: server.AutoRefactorApply})
+	t.Cleanup(func() { server.SetConfig(server.ConfigFromEnv()) })
+
+	create := func(folder, name string) *mcp.CallToolResultFor[any] {
+		result, err := server.CreateMarkdownFile(context.Background(), nil, &mcp.CallToolParamsFor[server.CreateMarkdownParams]{
+			Arguments: server.CreateMarkdownParams{Name: name, Path: folder, Content: "# Note\n"},
+		})
+		require.NoError(t, err)
+		require.False(t, result.IsError)
+		return result
+	}
+
+	create("", "note.md")
+	require.FileExists(t, filepath.Join(root, "note.md"))
+	require.DirExists(t, filepath.Join(root, "doc"))
+	require.DirExists(t, filepath.Join(root, "pkg0"))
+
+	ops, err := server.ListOperationsLogic("doc")
+	require.NoError(t, err)
+	require.Len(t, ops, 1)
+	require.Len(t, ops[0].Files, 1)
+	require.Equal(t, filepath.Join(root, "note.md"), ops[0].Files[0].Path)
+
+	create("doc/guides", "alpha_99.md")
+	require.FileExists(t, filepath.Join(root, "doc", "guides", "alpha", "alpha_99.md"))
+	require.FileExists(t, filepath.Join(root, "doc", "guides", "beta", "beta_1.md"))
+}
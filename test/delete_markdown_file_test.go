@@ -0,0 +1,80 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/shardqa/doc-mcp/internal/server"
+	"github.com/stretchr/testify/require"
+)
+
+var deleteTree = map[string]string{
+	"old.md":  "# Old\n",
+	"new.md":  "# New\n",
+	"home.md": "See [the old page](old.md) for details.\n",
+}
+
+func TestDeleteMarkdownFile_RefusesWhenLinked(t *testing.T) {
+	root := writeTree(t, deleteTree)
+
+	result, err := server.DeleteMarkdownFileLogic(root, "old.md", server.DeleteOptions{})
+	require.Error(t, err)
+	require.Equal(t, []string{filepath.Join(root, "home.md")}, result.Referrers)
+	require.FileExists(t, filepath.Join(root, "old.md"))
+}
+
+func TestDeleteMarkdownFile_ForceMovesToTrash(t *testing.T) {
+	root := writeTree(t, deleteTree)
+
+	result, err := server.DeleteMarkdownFileLogic(root, "old.md", server.DeleteOptions{Force: true})
+	require.NoError(t, err)
+	require.NoFileExists(t, filepath.Join(root, "old.md"))
+	require.FileExists(t, result.Trashed)
+	require.Contains(t, result.Trashed, filepath.Join(root, ".doc-mcp", "trash"))
+
+	home, err := os.ReadFile(filepath.Join(root, "home.md"))
+	require.NoError(t, err)
+	require.Contains(t, string(home), "(old.md)")
+}
+
+func TestDeleteMarkdownFile_Replacement(t *testing.T) {
+	root := writeTree(t, deleteTree)
+
+	result, err := server.DeleteMarkdownFileLogic(root, "old.md", server.DeleteOptions{Replacement: "new.md"})
+	require.NoError(t, err)
+	require.Len(t, result.Updated, 1)
+
+	home, err := os.ReadFile(filepath.Join(root, "home.md"))
+	require.NoError(t, err)
+	require.Equal(t, "See [the old page](new.md) for details.\n", string(home))
+}
+
+func TestDeleteMarkdownFile_RejectsReplacementInsideTarget(t *testing.T) {
+	root := writeTree(t, deleteTree)
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "archive"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "archive", "a.md"), []byte("# A\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "index.md"), []byte("[A](archive/a.md)\n"), 0644))
+
+	_, err := server.DeleteMarkdownFileLogic(root, "old.md", server.DeleteOptions{Replacement: "old.md"})
+	require.Error(t, err)
+	_, err = server.DeleteMarkdownFileLogic(root, "archive", server.DeleteOptions{Replacement: "archive/a.md"})
+	require.Error(t, err)
+
+	require.FileExists(t, filepath.Join(root, "old.md"))
+	require.FileExists(t, filepath.Join(root, "archive", "a.md"))
+	home, err := os.ReadFile(filepath.Join(root, "home.md"))
+	require.NoError(t, err)
+	require.Equal(t, "See [the old page](old.md) for details.\n", string(home))
+}
+
+func TestDeleteMarkdownFile_StripLinks(t *testing.T) {
+	root := writeTree(t, deleteTree)
+
+	_, err := server.DeleteMarkdownFileLogic(root, "old.md", server.DeleteOptions{StripLinks: true})
+	require.NoError(t, err)
+
+	home, err := os.ReadFile(filepath.Join(root, "home.md"))
+	require.NoError(t, err)
+	require.Equal(t, "See the old page for details.\n", string(home))
+}
@@ -0,0 +1,88 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/shardqa/doc-mcp/internal/server"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDocHistoryDiffAndBlame(t *testing.T) {
+	repo := initGitRepo(t)
+	root := filepath.Join(repo, "doc")
+
+	require.NoError(t, os.WriteFile(filepath.Join(root, "home.md"), []byte("# Home\n\nWelcome.\n"), 0644))
+	runGit(t, repo, "commit", "-q", "-am", "add welcome")
+
+	commits, err := server.DocHistoryLogic(root, "home.md", 0)
+	require.NoError(t, err)
+	require.Len(t, commits, 2)
+	require.Equal(t, "add welcome", commits[0].Subject)
+	require.Equal(t, "initial", commits[1].Subject)
+	require.Equal(t, "Tester", commits[0].Author)
+
+	limited, err := server.DocHistoryLogic(root, "home.md", 1)
+	require.NoError(t, err)
+	require.Len(t, limited, 1)
+
+	hunks, err := server.DocDiffLogic(root, "home.md", commits[1].Hash, commits[0].Hash)
+	require.NoError(t, err)
+	require.Len(t, hunks, 1)
+	added := []server.DiffLine{}
+	for _, line := range hunks[0].Lines {
+		if line.Kind == "added" {
+			added = append(added, line)
+		}
+	}
+	require.Equal(t, []server.DiffLine{
+		{Kind: "added", NewLine: 2, Text: ""},
+		{Kind: "added", NewLine: 3, Text: "Welcome."},
+	}, added)
+
+	out := filepath.Join(t.TempDir(), "out")
+	_, err = server.DocDiffLogic(root, "home.md", "--output="+out, "")
+	require.Error(t, err)
+	_, err = server.DocDiffLogic(root, "home.md", "HEAD", "--output="+out)
+	require.Error(t, err)
+	_, err = server.DocDiffLogic(root, "home.md", "no-such-branch", "")
+	require.Error(t, err)
+	require.NoFileExists(t, out)
+
+	require.NoError(t, os.WriteFile(filepath.Join(root, "home.md"), []byte("# Home\n\nWelcome back.\n"), 0644))
+	hunks, err = server.DocDiffLogic(root, "home.md", "", "")
+	require.NoError(t, err)
+	require.Len(t, hunks, 1)
+	require.Contains(t, hunks[0].Lines, server.DiffLine{Kind: "removed", OldLine: 3, Text: "Welcome."})
+	require.Contains(t, hunks[0].Lines, server.DiffLine{Kind: "added", NewLine: 3, Text: "Welcome back."})
+
+	blame, err := server.DocBlameLogic(root, "home.md")
+	require.NoError(t, err)
+	require.Len(t, blame, 3)
+	require.Equal(t, commits[1].Hash, blame[0].Hash)
+	require.Equal(t, "initial", blame[0].Summary)
+	require.Equal(t, "# Home", blame[0].Text)
+	require.Equal(t, "tester@example.com", blame[0].Email)
+	require.Equal(t, 3, blame[2].Line)
+	require.Equal(t, "Welcome back.", blame[2].Text)
+	require.NotEqual(t, commits[0].Hash, blame[2].Hash)
+}
+
+func TestDocBlame_SHA256Repository(t *testing.T) {
+	repo := t.TempDir()
+	runGit(t, repo, "init", "-q", "--object-format=sha256")
+	root := filepath.Join(repo, "doc")
+	require.NoError(t, os.MkdirAll(root, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "home.md"), []byte("# Home\n\nWelcome.\n"), 0644))
+	runGit(t, repo, "add", "-A")
+	runGit(t, repo, "commit", "-q", "-m", "initial")
+
+	blame, err := server.DocBlameLogic(root, "home.md")
+	require.NoError(t, err)
+	require.Len(t, blame, 3)
+	require.Len(t, blame[0].Hash, 64)
+	require.Equal(t, runGit(t, repo, "rev-parse", "HEAD"), blame[0].Hash)
+	require.Equal(t, "initial", blame[2].Summary)
+	require.Equal(t, "Welcome.", blame[2].Text)
+}
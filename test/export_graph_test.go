@@ -0,0 +1,66 @@
+package test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/shardqa/doc-mcp/internal/server"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportGraphLogic_JSON(t *testing.T) {
+	root := writeTree(t, graphTree)
+
+	out, err := server.ExportGraphLogic(root, server.ExportOptions{})
+	require.NoError(t, err)
+	var export server.GraphExport
+	require.NoError(t, json.Unmarshal([]byte(out), &export))
+	require.Len(t, export.Nodes, 5)
+	require.Equal(t, "api", export.Nodes[0].Folder)
+	require.Len(t, export.Edges, 4)
+
+	out, err = server.ExportGraphLogic(root, server.ExportOptions{Path: "api"})
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal([]byte(out), &export))
+	require.Len(t, export.Nodes, 2)
+	require.Equal(t, []server.GraphEdge{{Source: "api/reference.md", Target: "api/details.md", Text: "details"}}, export.Edges)
+}
+
+func TestExportGraphLogic_DOTAndMermaid(t *testing.T) {
+	root := writeTree(t, graphTree)
+
+	dot, err := server.ExportGraphLogic(root, server.ExportOptions{Format: "dot", Cluster: true})
+	require.NoError(t, err)
+	require.Contains(t, dot, "digraph docs {")
+	require.Contains(t, dot, "subgraph cluster_0 {\n    label=\"api/\";\n    \"api/details.md\" [label=\"Details\"];")
+	require.Contains(t, dot, "  \"home.md\" -> \"guides/guide.md\";\n")
+	require.NotContains(t, dot, "gone.md")
+
+	mermaid, err := server.ExportGraphLogic(root, server.ExportOptions{Format: "mermaid"})
+	require.NoError(t, err)
+	require.Equal(t, "flowchart LR\n"+
+		"  n0[\"Details\"]\n"+
+		"  n1[\"Reference\"]\n"+
+		"  n2[\"Guide\"]\n"+
+		"  n3[\"Home\"]\n"+
+		"  n4[\"Lonely\"]\n"+
+		"  n1 --> n0\n"+
+		"  n2 --> n3\n"+
+		"  n2 --> n1\n"+
+		"  n3 --> n2\n", mermaid)
+
+	_, err = server.ExportGraphLogic(root, server.ExportOptions{Format: "svg"})
+	require.Error(t, err)
+}
+
+func TestExportGraphLogic_Tag(t *testing.T) {
+	root := writeTree(t, graphTree)
+	require.NoError(t, os.WriteFile(filepath.Join(root, "lonely.md"), []byte("---\ntags: [api]\n---\n# Lonely\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "api", "details.md"), []byte("# Details\n\nSee [lonely](../lonely.md). #api\n"), 0644))
+
+	mermaid, err := server.ExportGraphLogic(root, server.ExportOptions{Format: "mermaid", Tag: "api"})
+	require.NoError(t, err)
+	require.Equal(t, "flowchart LR\n  n0[\"Details\"]\n  n1[\"Lonely\"]\n  n0 --> n1\n", mermaid)
+}
@@ -0,0 +1,53 @@
+package test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/shardqa/doc-mcp/internal/server"
+	"github.com/stretchr/testify/require"
+)
+
+const deployGuide = "# Deploying\n\nBuild the image with make release, push it to the registry and roll it out with the deploy script. Watch the dashboard for errors during the rollout and roll back with the previous tag if the error rate rises above one percent.\n"
+
+var duplicateTree = map[string]string{
+	"deploy.md":        deployGuide,
+	"ops/releasing.md": "---\ntitle: Releasing\n---\n" + deployGuide + "\nAnnounce the release in the team channel.\n",
+	"glossary.md":      "# Glossary\n\nA rollout is the gradual replacement of running instances with a new version of the service.\n",
+	"short.md":         "# Todo\n",
+}
+
+func TestFindDuplicatesLogic(t *testing.T) {
+	root := writeTree(t, duplicateTree)
+
+	pairs, err := server.FindDuplicatesLogic(root, "", 0)
+	require.NoError(t, err)
+	require.Len(t, pairs, 1)
+	require.Equal(t, "deploy.md", pairs[0].First)
+	require.Equal(t, "ops/releasing.md", pairs[0].Second)
+	require.Greater(t, pairs[0].Overlap, 0.8)
+	require.Less(t, pairs[0].Similarity, 1.0)
+
+	pairs, err = server.FindDuplicatesLogic(root, "ops", 0)
+	require.NoError(t, err)
+	require.Empty(t, pairs)
+}
+
+func TestCheckDuplicatesLogic(t *testing.T) {
+	root := writeTree(t, duplicateTree)
+
+	draft := "# How to deploy\n\n" + deployGuide[len("# Deploying\n\n"):]
+	matches, err := server.CheckDuplicatesLogic(root, draft, "", 0)
+	require.NoError(t, err)
+	require.Len(t, matches, 2)
+	require.ElementsMatch(t, []string{"deploy.md", "ops/releasing.md"}, []string{matches[0].Path, matches[1].Path})
+
+	matches, err = server.CheckDuplicatesLogic(root, draft, filepath.Join(root, "deploy.md"), 0)
+	require.NoError(t, err)
+	require.Len(t, matches, 1)
+	require.Equal(t, "ops/releasing.md", matches[0].Path)
+
+	matches, err = server.CheckDuplicatesLogic(root, "# Caching\n\nThe cache is warmed on startup from the snapshot in object storage.\n", "", 0)
+	require.NoError(t, err)
+	require.Empty(t, matches)
+}
@@ -0,0 +1,107 @@
+package test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/shardqa/doc-mcp/internal/server"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateIndexes(t *testing.T) {
+	tempDir := t.TempDir()
+
+	require.NoError(t, os.MkdirAll(filepath.Join(tempDir, "api"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "setup.md"), []byte("# Setup Guide\n\nHow to install the tools.\nSecond line.\n\nMore text."), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "api", "auth.md"), []byte("---\ntitle: Authentication\n---\nTokens are issued by the gateway."), 0644))
+
+	written, err := server.GenerateIndexesLogic(tempDir, tempDir)
+	require.NoError(t, err)
+	require.Len(t, written, 2)
+
+	root, err := os.ReadFile(filepath.Join(tempDir, "index.md"))
+	require.NoError(t, err)
+	require.Contains(t, string(root), "- [api/](api/index.md)")
+	require.Contains(t, string(root), "- [Setup Guide](setup.md) - How to install the tools. Second line.")
+
+	api, err := os.ReadFile(filepath.Join(tempDir, "api", "index.md"))
+	require.NoError(t, err)
+	require.Contains(t, string(api), "- [Authentication](auth.md) - Tokens are issued by the gateway.")
+}
+
+func TestGenerateIndexes_PreservesHandWrittenSection(t *testing.T) {
+	tempDir := t.TempDir()
+
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "one.md"), []byte("# One\n"), 0644))
+	intro := "# Handbook\n\nRead this first.\n\n"
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "index.md"), []byte(intro+"<!-- doc-mcp:index:start -->\nstale\n<!-- doc-mcp:index:end -->\n"), 0644))
+
+	_, err := server.GenerateIndexesLogic(tempDir, tempDir)
+	require.NoError(t, err)
+
+	content, err := os.ReadFile(filepath.Join(tempDir, "index.md"))
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(string(content), intro))
+	require.NotContains(t, string(content), "stale")
+	require.Contains(t, string(content), "- [One](one.md)")
+	require.NotContains(t, string(content), "(index.md)")
+}
+
+func TestGenerateIndexes_SkipsOnlyRootTemplates(t *testing.T) {
+	tempDir := writeTree(t, map[string]string{
+		"templates/adr.md":            "# ADR template\n",
+		"guides/templates/email.md":   "# Email templates\n",
+		"guides/setup.md":             "# Setup\n\n![diagram](assets/diagram.png)\n",
+		"guides/assets/diagram.png":   "png",
+		"branding/assets/logo-use.md": "# Logo use\n",
+	})
+
+	_, err := server.GenerateIndexesLogic(tempDir, tempDir)
+	require.NoError(t, err)
+
+	require.NoFileExists(t, filepath.Join(tempDir, "templates", "index.md"))
+	require.FileExists(t, filepath.Join(tempDir, "guides", "templates", "index.md"))
+
+	guides, err := os.ReadFile(filepath.Join(tempDir, "guides", "index.md"))
+	require.NoError(t, err)
+	require.Contains(t, string(guides), "- [templates/](templates/index.md)")
+	require.NotContains(t, string(guides), "assets/")
+
+	branding, err := os.ReadFile(filepath.Join(tempDir, "branding", "index.md"))
+	require.NoError(t, err)
+	require.Contains(t, string(branding), "- [assets/](assets/index.md)")
+}
+
+func TestGenerateIndexes_EscapesDestinations(t *testing.T) {
+	tempDir := writeTree(t, map[string]string{
+		"release notes/v1.md": "# V1\n",
+		"faq (old).md":        "# Old FAQ\n",
+	})
+
+	_, err := server.GenerateIndexesLogic(tempDir, tempDir)
+	require.NoError(t, err)
+
+	content, err := os.ReadFile(filepath.Join(tempDir, "index.md"))
+	require.NoError(t, err)
+	require.Contains(t, string(content), "- [release notes/](release%20notes/index.md)")
+	require.Contains(t, string(content), "- [Old FAQ](faq%20%28old%29.md)")
+}
+
+func TestGenerateIndexes_RejectsPathsOutsideDoc(t *testing.T) {
+	root := t.TempDir()
+	t.Chdir(root)
+	require.NoError(t, os.MkdirAll("doc", 0755))
+
+	for _, folder := range []string{".", "../", root} {
+		result, err := server.GenerateIndexes(context.Background(), nil, &mcp.CallToolParamsFor[server.GenerateIndexesParams]{
+			Arguments: server.GenerateIndexesParams{FolderPath: folder},
+		})
+		require.NoError(t, err)
+		require.True(t, result.IsError, folder)
+	}
+	require.NoFileExists(t, filepath.Join(root, "index.md"))
+}
@@ -0,0 +1,133 @@
+package test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shardqa/doc-mcp/internal/server"
+	"github.com/stretchr/testify/require"
+)
+
+func runGit(t *testing.T, dir string, args ...string) string {
+	args = append([]string{"-c", "user.name=Tester", "-c", "user.email=tester@example.com"}, args...)
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(out))
+	return strings.TrimSpace(string(out))
+}
+
+func initGitRepo(t *testing.T) string {
+	repo := t.TempDir()
+	runGit(t, repo, "init", "-q", "-b", "main")
+	require.NoError(t, os.MkdirAll(filepath.Join(repo, "doc"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(repo, "doc", "home.md"), []byte("# Home\n"), 0644))
+	runGit(t, repo, "add", "-A")
+	runGit(t, repo, "commit", "-q", "-m", "initial")
+	return repo
+}
+
+func TestCommitOperation(t *testing.T) {
+	repo := initGitRepo(t)
+	root := filepath.Join(repo, "doc")
+	require.NoError(t, os.WriteFile(filepath.Join(repo, "unrelated.txt"), []byte("keep me out"), 0644))
+
+	tracker, err := server.BeginOperation(root, "create_markdown_file", map[string]string{"name": "setup.md"}, root)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(root, "setup.md"), []byte("# Setup\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "home.md"), []byte("# Home\n\n[Setup](setup.md)\n"), 0644))
+	op, err := tracker.Finish()
+	require.NoError(t, err)
+
+	hash, ignored, err := server.CommitOperationLogic(op, server.GitOptions{
+		AutoCommit:  true,
+		Branch:      "docs-agent",
+		AuthorName:  "Doc Agent",
+		AuthorEmail: "agent@example.com",
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, hash)
+	require.Empty(t, ignored)
+
+	// The branch is committed to without being checked out.
+	require.Equal(t, "main", runGit(t, repo, "symbolic-ref", "--short", "HEAD"))
+	require.Equal(t, hash, runGit(t, repo, "rev-parse", "docs-agent"))
+	require.Equal(t, "Doc Agent <agent@example.com>", runGit(t, repo, "log", "-1", "--format=%an <%ae>", "docs-agent"))
+	require.Equal(t, "initial", runGit(t, repo, "log", "-1", "--format=%s", "main"))
+	status := runGit(t, repo, "status", "--porcelain")
+	require.Contains(t, status, "?? doc/setup.md")
+	require.Contains(t, status, "M doc/home.md")
+
+	message := runGit(t, repo, "log", "-1", "--format=%B", "docs-agent")
+	require.Contains(t, message, "doc-mcp: create_markdown_file (2 files)")
+	require.Contains(t, message, `Arguments: {"name":"setup.md"}`)
+	require.Contains(t, message, "- doc/setup.md (created)")
+	require.Contains(t, message, "- doc/home.md (modified)")
+
+	files := runGit(t, repo, "show", "--name-only", "--format=", "docs-agent")
+	require.NotContains(t, files, "unrelated.txt")
+	require.NotContains(t, files, ".doc-mcp")
+	require.Equal(t, "# Setup", runGit(t, repo, "show", "docs-agent:doc/setup.md"))
+
+	// A second operation builds on the branch, including deletions.
+	tracker, err = server.BeginOperation(root, "delete_markdown_file", map[string]string{"path": "setup.md"}, root)
+	require.NoError(t, err)
+	require.NoError(t, os.Remove(filepath.Join(root, "setup.md")))
+	op, err = tracker.Finish()
+	require.NoError(t, err)
+	next, _, err := server.CommitOperationLogic(op, server.GitOptions{AutoCommit: true, Branch: "docs-agent", AuthorName: "Doc Agent", AuthorEmail: "agent@example.com"})
+	require.NoError(t, err)
+	require.Equal(t, hash, runGit(t, repo, "rev-parse", "docs-agent^"))
+	require.Equal(t, next, runGit(t, repo, "rev-parse", "docs-agent"))
+	require.NotContains(t, runGit(t, repo, "ls-tree", "-r", "--name-only", "docs-agent"), "setup.md")
+}
+
+func TestCommitOperation_CurrentBranch(t *testing.T) {
+	repo := initGitRepo(t)
+	root := filepath.Join(repo, "doc")
+	require.NoError(t, os.WriteFile(filepath.Join(repo, ".gitignore"), []byte("doc/drafts/\n"), 0644))
+
+	tracker, err := server.BeginOperation(root, "create_markdown_file", nil, root)
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "drafts"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "drafts", "idea.md"), []byte("# Idea\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "setup.md"), []byte("# Setup\n"), 0644))
+	op, err := tracker.Finish()
+	require.NoError(t, err)
+
+	hash, ignored, err := server.CommitOperationLogic(op, server.GitOptions{AutoCommit: true, AuthorName: "Doc Agent", AuthorEmail: "agent@example.com"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"doc/drafts/idea.md"}, ignored)
+	require.Equal(t, hash, runGit(t, repo, "rev-parse", "HEAD"))
+	files := runGit(t, repo, "show", "--name-only", "--format=", "HEAD")
+	require.Contains(t, files, "doc/setup.md")
+	require.NotContains(t, files, "idea.md")
+}
+
+func TestCommitOperation_NothingToCommit(t *testing.T) {
+	hash, _, err := server.CommitOperationLogic(nil, server.GitOptions{AutoCommit: true})
+	require.NoError(t, err)
+	require.Empty(t, hash)
+}
+
+func TestCommitOperation_TruncatesArgumentsOnRuneBoundary(t *testing.T) {
+	repo := initGitRepo(t)
+	root := filepath.Join(repo, "doc")
+
+	content := "a" + strings.Repeat("é", 150)
+	tracker, err := server.BeginOperation(root, "edit_markdown_file", map[string]string{"content": content}, root)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(root, "home.md"), []byte(content), 0644))
+	op, err := tracker.Finish()
+	require.NoError(t, err)
+
+	_, _, err = server.CommitOperationLogic(op, server.GitOptions{AutoCommit: true, AuthorName: "Doc Agent", AuthorEmail: "agent@example.com"})
+	require.NoError(t, err)
+
+	message := runGit(t, repo, "log", "-1", "--format=%B")
+	require.Contains(t, message, `{"content":"a`+strings.Repeat("é", 99)+`..."}`)
+	require.NotContains(t, message, `�`)
+}
@@ -4,7 +4,9 @@ import (
 	"bufio"
 	"encoding/json"
 	"io"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -169,4 +171,16 @@ func ValidateJSONRPCResponse(t *testing.T, resp JSONRPCResponse, expectedID stri
 	require.NotEmpty(t, result.Content)
 
 	return result
-} 
\ No newline at end of file
+}
+
+// writeTree writes files, keyed by their slash-separated path, into a new
+// temporary folder and returns the folder.
+func writeTree(t *testing.T, files map[string]string) string {
+	root := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	return root
+}
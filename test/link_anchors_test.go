@@ -0,0 +1,119 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shardqa/doc-mcp/internal/server"
+	"github.com/stretchr/testify/require"
+)
+
+var anchorTree = map[string]string{
+	"guide.md": "# Guide\n\nJump to [usage](#old-name).\n\n## Old Name\n\nFirst body.\n\n### Detail\n\nNested.\n\n## Next\n\nLast body.\n",
+	"home.md":  "# Home\n\nRead [the guide](guide.md#old-name), [its query](guide.md?v=2#old-name) and [[Guide#Old Name|notes]].\nAlso [next](guide.md#next).\n",
+}
+
+func TestMoveMarkdownFile_KeepsFragmentsAndQueries(t *testing.T) {
+	root := writeTree(t, anchorTree)
+
+	_, _, err := server.MoveMarkdownFileLogic(root, "guide.md", "guides/guide.md")
+	require.NoError(t, err)
+
+	home, err := os.ReadFile(filepath.Join(root, "home.md"))
+	require.NoError(t, err)
+	require.Contains(t, string(home), "[the guide](guides/guide.md#old-name)")
+	require.Contains(t, string(home), "[its query](guides/guide.md?v=2#old-name)")
+	require.Contains(t, string(home), "[next](guides/guide.md#next)")
+}
+
+func TestDeleteMarkdownFile_SeesFragmentLinks(t *testing.T) {
+	root := writeTree(t, anchorTree)
+
+	_, err := server.DeleteMarkdownFileLogic(root, "guide.md", server.DeleteOptions{})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "home.md")
+}
+
+func TestEditSectionLogic_RenameRewritesLinks(t *testing.T) {
+	root := writeTree(t, anchorTree)
+
+	result, err := server.EditSectionLogic(root, "guide.md", "Old Name", "New Name", "")
+	require.NoError(t, err)
+	require.Equal(t, "new-name", result.Anchor)
+	require.Equal(t, "old-name", result.OldAnchor)
+	require.Len(t, result.Updated, 2)
+
+	guide, err := os.ReadFile(filepath.Join(root, "guide.md"))
+	require.NoError(t, err)
+	require.Equal(t, "# Guide\n\nJump to [usage](#new-name).\n\n## New Name\n\nFirst body.\n\n### Detail\n\nNested.\n\n## Next\n\nLast body.\n", string(guide))
+
+	home, err := os.ReadFile(filepath.Join(root, "home.md"))
+	require.NoError(t, err)
+	require.Equal(t, "# Home\n\nRead [the guide](guide.md#new-name), [its query](guide.md?v=2#new-name) and [[Guide#New Name|notes]].\nAlso [next](guide.md#next).\n", string(home))
+}
+
+func TestEditSectionLogic_ReplacesBody(t *testing.T) {
+	root := writeTree(t, anchorTree)
+
+	result, err := server.EditSectionLogic(root, "guide.md", "#old-name", "", "Replaced body.")
+	require.NoError(t, err)
+	require.Empty(t, result.OldAnchor)
+
+	guide, err := os.ReadFile(filepath.Join(root, "guide.md"))
+	require.NoError(t, err)
+	require.Equal(t, "# Guide\n\nJump to [usage](#old-name).\n\n## Old Name\n\nReplaced body.\n\n## Next\n\nLast body.\n", string(guide))
+
+	_, err = server.EditSectionLogic(root, "guide.md", "Missing", "", "Body.")
+	require.Error(t, err)
+}
+
+func TestValidateMarkdownFile_BrokenAnchor(t *testing.T) {
+	initReq := `{"jsonrpc":"2.0","id":"init","method":"initialize","params":{"protocolVersion":"2024-11-05","capabilities":{},"clientInfo":{"name":"test","version":"1.0"}}}`
+	initNotif := `{"jsonrpc":"2.0","method":"notifications/initialized"}`
+	toolCall := `{"jsonrpc":"2.0","id":"1","method":"tools/call","params":{"name":"validate_markdown_file","arguments":{"content":"# Title\n\nSee [usage](#usage) and [setup](#setup).\n\n## Usage\n\nText.\n"}}}`
+
+	resp, _, _ := runMCP(initReq + "\n" + initNotif + "\n" + toolCall)
+	result := resp["result"].(map[string]interface{})
+	content := result["content"].([]interface{})
+	require.Len(t, content, 2)
+	warnings := content[1].(map[string]interface{})["text"].(string)
+	require.True(t, strings.Contains(warnings, "#setup does not match any heading"), warnings)
+	require.False(t, strings.Contains(warnings, "#usage"), warnings)
+}
+
+func TestEditSectionLogic_RenameShiftsDuplicateAnchors(t *testing.T) {
+	root := t.TempDir()
+	guide := "# Guide\n\n## Usage\n\nCLI.\n\n## Usage\n\nAPI.\n"
+	home := "# Home\n\nSee [the CLI](guide.md#usage) and [the API](guide.md#usage-1).\n"
+	require.NoError(t, os.WriteFile(filepath.Join(root, "guide.md"), []byte(guide), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "home.md"), []byte(home), 0644))
+
+	result, err := server.EditSectionLogic(root, "guide.md", "usage", "CLI usage", "")
+	require.NoError(t, err)
+	require.Equal(t, "cli-usage", result.Anchor)
+	require.Equal(t, []string{filepath.Join(root, "home.md")}, result.Updated)
+
+	content, err := os.ReadFile(filepath.Join(root, "home.md"))
+	require.NoError(t, err)
+	require.Equal(t, "# Home\n\nSee [the CLI](guide.md#cli-usage) and [the API](guide.md#usage).\n", string(content))
+}
+
+func TestEditSectionLogic_RenameRewritesSamePageWikiLinks(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"guide.md": "# Guide\n\nSee [[#Old Name]] or [[#Old Name|below]].\n\n## Old Name\n\nBody.\n",
+		"home.md":  "# Home\n\nNot this [[#Old Name]].\n",
+	})
+
+	_, err := server.EditSectionLogic(root, "guide.md", "Old Name", "New Name", "")
+	require.NoError(t, err)
+
+	guide, err := os.ReadFile(filepath.Join(root, "guide.md"))
+	require.NoError(t, err)
+	require.Equal(t, "# Guide\n\nSee [[#New Name]] or [[#New Name|below]].\n\n## New Name\n\nBody.\n", string(guide))
+
+	home, err := os.ReadFile(filepath.Join(root, "home.md"))
+	require.NoError(t, err)
+	require.Equal(t, "# Home\n\nNot this [[#Old Name]].\n", string(home))
+}
@@ -0,0 +1,97 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/shardqa/doc-mcp/internal/server"
+	"github.com/stretchr/testify/require"
+)
+
+var graphTree = map[string]string{
+	"home.md":          "# Home\n\nStart with the [guide](guides/guide.md).\nSee also [missing](gone.md).\n",
+	"guides/guide.md":  "# Guide\n\nBack [home](../home.md) or read the [reference](../api/reference.md).\n",
+	"api/reference.md": "# Reference\n\nEndpoints are listed in [details](details.md).\n",
+	"api/details.md":   "# Details\n\nNothing links out of here.\n",
+	"lonely.md":        "# Lonely\n\nNo links at all.\n",
+}
+
+func TestLinkGraph_BacklinksAndOutgoing(t *testing.T) {
+	root := writeTree(t, graphTree)
+
+	backlinks, err := server.GetBacklinksLogic(root, "guides/guide.md")
+	require.NoError(t, err)
+	require.Equal(t, []server.LinkRef{{
+		Path:    "home.md",
+		Title:   "Home",
+		Text:    "guide",
+		Line:    3,
+		Context: "Start with the [guide](guides/guide.md).",
+	}}, backlinks)
+
+	outgoing, err := server.GetOutgoingLinksLogic(root, "home.md")
+	require.NoError(t, err)
+	require.Len(t, outgoing, 2)
+	require.Equal(t, "guides/guide.md", outgoing[0].Path)
+	require.Equal(t, "Guide", outgoing[0].Title)
+	require.Equal(t, "gone.md", outgoing[1].Path)
+	require.True(t, outgoing[1].Missing)
+
+	_, err = server.GetBacklinksLogic(root, "nope.md")
+	require.Error(t, err)
+}
+
+func TestLinkGraph_LinksWithoutText(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"home.md":  "# Home\n\nIntro.\n\n[](guide.md) and [![logo](logo.png)](guide.md)\n",
+		"guide.md": "# Guide\n",
+	})
+
+	backlinks, err := server.GetBacklinksLogic(root, "guide.md")
+	require.NoError(t, err)
+	require.Len(t, backlinks, 2)
+	for _, link := range backlinks {
+		require.Equal(t, 5, link.Line)
+		require.Equal(t, "[](guide.md) and [![logo](logo.png)](guide.md)", link.Context)
+	}
+}
+
+func TestLinkGraph_Neighbors(t *testing.T) {
+	root := writeTree(t, graphTree)
+
+	neighborhood, err := server.GetNeighborsLogic(root, "guides/guide.md", 0)
+	require.NoError(t, err)
+	require.Equal(t, []server.GraphNode{
+		{Path: "guides/guide.md", Title: "Guide", Distance: 0},
+		{Path: "api/reference.md", Title: "Reference", Distance: 1},
+		{Path: "home.md", Title: "Home", Distance: 1},
+	}, neighborhood.Nodes)
+	require.Len(t, neighborhood.Edges, 3)
+
+	neighborhood, err = server.GetNeighborsLogic(root, "guides/guide.md", 2)
+	require.NoError(t, err)
+	require.Len(t, neighborhood.Nodes, 4)
+	require.Equal(t, "api/details.md", neighborhood.Nodes[3].Path)
+	require.Equal(t, 2, neighborhood.Nodes[3].Distance)
+}
+
+func TestLinkGraph_Update(t *testing.T) {
+	root := writeTree(t, graphTree)
+	graph := server.NewLinkGraph(root)
+	require.NoError(t, graph.Build())
+
+	lonely := filepath.Join(root, "lonely.md")
+	require.NoError(t, os.WriteFile(lonely, []byte("# Lonely\n\nNow points [home](home.md).\n"), 0644))
+	graph.Update(lonely)
+
+	backlinks, err := graph.Backlinks("home.md")
+	require.NoError(t, err)
+	require.Len(t, backlinks, 2)
+	require.Equal(t, "lonely.md", backlinks[1].Path)
+
+	require.NoError(t, os.Remove(lonely))
+	graph.Update(lonely)
+	_, err = graph.Outgoing("lonely.md")
+	require.Error(t, err)
+}
@@ -0,0 +1,50 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/shardqa/doc-mcp/internal/server"
+	"github.com/stretchr/testify/require"
+)
+
+func entryPaths(entries []server.ReportEntry) []string {
+	paths := []string{}
+	for _, e := range entries {
+		paths = append(paths, e.Path)
+	}
+	return paths
+}
+
+func TestLinkReportLogic(t *testing.T) {
+	root := writeTree(t, graphTree)
+	require.NoError(t, os.WriteFile(filepath.Join(root, "lonely.md"), []byte("# Lonely\n\nThe reference endpoints return JSON details.\n"), 0644))
+
+	report, err := server.LinkReportLogic(root)
+	require.NoError(t, err)
+	require.Equal(t, 5, report.Documents)
+	require.Equal(t, []string{"lonely.md"}, entryPaths(report.Orphans))
+	require.Equal(t, []string{"api/details.md", "lonely.md"}, entryPaths(report.DeadEnds))
+	require.Equal(t, [][]string{
+		{"api/details.md", "api/reference.md", "guides/guide.md", "home.md"},
+		{"lonely.md"},
+	}, report.Components)
+
+	suggestions := report.Orphans[0].Suggestions
+	require.NotEmpty(t, suggestions)
+	require.Equal(t, "api/reference.md", suggestions[0].Path)
+	require.Contains(t, suggestions[0].SharedTerms, "endpoints")
+}
+
+func TestLinkReportLogic_Connected(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "a.md"), []byte("# A\n\n[b](b.md)\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "b.md"), []byte("# B\n\n[a](a.md)\n"), 0644))
+
+	report, err := server.LinkReportLogic(root)
+	require.NoError(t, err)
+	require.Empty(t, report.Orphans)
+	require.Empty(t, report.DeadEnds)
+	require.Empty(t, report.Components)
+}
@@ -0,0 +1,84 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/shardqa/doc-mcp/internal/server"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMoveMarkdownFile_RewritesInboundAndOutboundLinks(t *testing.T) {
+	root := t.TempDir()
+
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "guides"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "home.md"), []byte("[Setup](setup.md) and [FAQ](faq.md)\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "faq.md"), []byte("[Home](home.md)\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "setup.md"), []byte("[Home](home.md)\n"), 0644))
+
+	dest, updated, err := server.MoveMarkdownFileLogic(root, "setup.md", "guides/install.md")
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(root, "guides", "install.md"), dest)
+	require.Len(t, updated, 2)
+
+	home, err := os.ReadFile(filepath.Join(root, "home.md"))
+	require.NoError(t, err)
+	require.Equal(t, "[Setup](guides/install.md) and [FAQ](faq.md)\n", string(home))
+
+	moved, err := os.ReadFile(dest)
+	require.NoError(t, err)
+	require.Equal(t, "[Home](../home.md)\n", string(moved))
+
+	faq, err := os.ReadFile(filepath.Join(root, "faq.md"))
+	require.NoError(t, err)
+	require.Equal(t, "[Home](home.md)\n", string(faq))
+}
+
+func TestMoveMarkdownFile_Folder(t *testing.T) {
+	root := t.TempDir()
+
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "api"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "reference"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "home.md"), []byte("[Auth](api/auth.md)\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "api", "auth.md"), []byte("[Home](../home.md)\n"), 0644))
+
+	dest, _, err := server.MoveMarkdownFileLogic(root, "api", "reference")
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(root, "reference", "api"), dest)
+
+	home, err := os.ReadFile(filepath.Join(root, "home.md"))
+	require.NoError(t, err)
+	require.Equal(t, "[Auth](reference/api/auth.md)\n", string(home))
+
+	auth, err := os.ReadFile(filepath.Join(dest, "auth.md"))
+	require.NoError(t, err)
+	require.Equal(t, "[Home](../../home.md)\n", string(auth))
+}
+
+func TestMoveMarkdownFile_RejectsPathsOutsideRoot(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "a.md"), []byte("# A\n"), 0644))
+
+	_, _, err := server.MoveMarkdownFileLogic(root, "a.md", "../a.md")
+	require.Error(t, err)
+	require.FileExists(t, filepath.Join(root, "a.md"))
+}
+
+func TestMoveMarkdownFile_EscapedDestinations(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "my_file.md"), []byte("# Mine\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "home.md"), []byte("[a](my\\_file.md), [b](my&#95;file.md#mine) and [c][r]\n\n[r]: <my\\_file.md>\n"), 0644))
+
+	_, updated, err := server.MoveMarkdownFileLogic(root, "my_file.md", "sub/my_file.md")
+	require.NoError(t, err)
+	require.Len(t, updated, 1)
+
+	home, err := os.ReadFile(filepath.Join(root, "home.md"))
+	require.NoError(t, err)
+	require.Equal(t, "[a](sub/my_file.md), [b](sub/my_file.md#mine) and [c][r]\n\n[r]: <sub/my_file.md>\n", string(home))
+
+	backlinks, err := server.GetBacklinksLogic(root, "sub/my_file.md")
+	require.NoError(t, err)
+	require.Len(t, backlinks, 3)
+}
@@ -0,0 +1,97 @@
+package test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/shardqa/doc-mcp/internal/server"
+	"github.com/stretchr/testify/require"
+)
+
+var promptTree = map[string]string{
+	"caching.md":             "# Caching\n\nThe page cache keeps rendered pages. See [setup](setup.md) and [search](search.md).\n",
+	"search.md":              "# Search\n\nFull text search over [caching](caching.md) and [setup](setup.md).\n",
+	"setup.md":               "# Setup\n\nInstall it. See [caching](caching.md#missing) and [search](search.md).\n",
+	"adr/0001-use-go.md":     "# Use Go\n\nStatus: accepted. See [SQLite](0002-use-sqlite.md) and [setup](../setup.md).\n",
+	"adr/0002-use-sqlite.md": "# Use SQLite\n\nStatus: accepted. See [Go](0001-use-go.md) and [search](../search.md).\n",
+}
+
+func promptResources(t *testing.T, result *mcp.GetPromptResult) map[string]string {
+	resources := make(map[string]string)
+	for _, message := range result.Messages[1:] {
+		resource, ok := message.Content.(*mcp.EmbeddedResource)
+		require.True(t, ok)
+		require.Equal(t, "text/markdown", resource.Resource.MIMEType)
+		resources[filepath.Base(resource.Resource.URI)] = resource.Resource.Text
+	}
+	return resources
+}
+
+func promptText(t *testing.T, result *mcp.GetPromptResult) string {
+	text, ok := result.Messages[0].Content.(*mcp.TextContent)
+	require.True(t, ok)
+	return text.Text
+}
+
+func TestDocumentFeaturePromptLogic(t *testing.T) {
+	root := writeTree(t, promptTree)
+
+	result, err := server.DocumentFeaturePromptLogic(root, "page cache", "guides")
+	require.NoError(t, err)
+	require.Contains(t, promptText(t, result), `Document the feature "page cache" in doc/guides.`)
+	require.Contains(t, promptText(t, result), "create_from_template")
+	require.Contains(t, promptResources(t, result), "caching.md")
+
+	_, err = server.DocumentFeaturePromptLogic(root, "", "")
+	require.Error(t, err)
+}
+
+func TestSummarizeAndFixPromptLogic(t *testing.T) {
+	root := writeTree(t, promptTree)
+
+	result, err := server.SummarizeFolderPromptLogic(root, "")
+	require.NoError(t, err)
+	require.Len(t, result.Messages, 6)
+	require.Contains(t, promptText(t, result), "- setup.md:\n  - ")
+	require.Contains(t, promptText(t, result), "#missing")
+
+	result, err = server.FixValidationWarningsPromptLogic(root, "")
+	require.NoError(t, err)
+	resources := promptResources(t, result)
+	require.Len(t, resources, 1)
+	require.Contains(t, resources, "setup.md")
+
+	result, err = server.FixValidationWarningsPromptLogic(root, "adr")
+	require.NoError(t, err)
+	require.Len(t, result.Messages, 1)
+	require.Contains(t, promptText(t, result), "no validation warnings")
+}
+
+func TestWriteADRPromptLogic(t *testing.T) {
+	root := writeTree(t, promptTree)
+
+	result, err := server.WriteADRPromptLogic(root, "Cache rendered pages", "Rendering is slow.")
+	require.NoError(t, err)
+	require.Contains(t, promptText(t, result), "Create it as adr/0003-cache-rendered-pages.md with create_from_template and the standard template.")
+	require.Contains(t, promptText(t, result), "Rendering is slow.")
+	require.Len(t, promptResources(t, result), 2)
+
+	result, err = server.WriteADRPromptLogic(root, "???", "")
+	require.NoError(t, err)
+	require.Contains(t, promptText(t, result), "Create it as adr/0003-decision.md")
+}
+
+func TestListPrompts(t *testing.T) {
+	initReq := `{"jsonrpc":"2.0","id":"init","method":"initialize","params":{"protocolVersion":"2024-11-05","capabilities":{},"clientInfo":{"name":"test","version":"1.0"}}}`
+	initNotif := `{"jsonrpc":"2.0","method":"notifications/initialized"}`
+	listCall := `{"jsonrpc":"2.0","id":"1","method":"prompts/list","params":{}}`
+
+	resp, _, _ := runMCP(initReq + "\n" + initNotif + "\n" + listCall)
+	result := resp["result"].(map[string]interface{})
+	names := []string{}
+	for _, p := range result["prompts"].([]interface{}) {
+		names = append(names, p.(map[string]interface{})["name"].(string))
+	}
+	require.ElementsMatch(t, []string{"document_feature", "summarize_folder", "fix_validation_warnings", "write_adr"}, names)
+}
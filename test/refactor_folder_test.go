@@ -1,11 +1,14 @@
 package test
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"testing"
 
+	"github.com/modelcontextprotocol/go-sdk/mcp"
 	"github.com/shardqa/doc-mcp/internal/server"
 	"github.com/stretchr/testify/assert"
 )
@@ -34,7 +37,7 @@ func TestRefactorFolder(t *testing.T) {
 	}
 
 	// Call the refactor function
-	err = server.RefactorFolderLogic(tempDir)
+	err = server.RefactorFolderLogic(tempDir, tempDir, server.RefactorOptions{})
 	assert.NoError(t, err)
 
 	// Assertions
@@ -61,4 +64,180 @@ func TestRefactorFolder(t *testing.T) {
 	assert.NoError(t, err)
 	expectedContent := "[link1](group1_file1.md)\n[link2](../group2/group2_file0.md)\n"
 	assert.Equal(t, expectedContent, string(updatedContent))
-} 
\ No newline at end of file
+}
+
+func TestRefactorFolder_SemanticStrategy(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "refactor-semantic-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	for i := 0; i < 6; i++ {
+		content := fmt.Sprintf("# Database notes %d\n\nPostgres database index tuning and query planner statistics.\n", i)
+		err := os.WriteFile(filepath.Join(tempDir, fmt.Sprintf("db%d.md", i)), []byte(content), 0644)
+		assert.NoError(t, err)
+	}
+	for i := 0; i < 6; i++ {
+		content := fmt.Sprintf("# Kubernetes notes %d\n\nKubernetes deployment rollout and pod scheduling on the cluster.\n", i)
+		err := os.WriteFile(filepath.Join(tempDir, fmt.Sprintf("k8s%d.md", i)), []byte(content), 0644)
+		assert.NoError(t, err)
+	}
+
+	err = server.RefactorFolderLogic(tempDir, tempDir, server.RefactorOptions{Strategy: server.StrategySemantic, Clusters: 2})
+	assert.NoError(t, err)
+
+	entries, err := os.ReadDir(tempDir)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+
+	for _, entry := range entries {
+		assert.True(t, entry.IsDir())
+		files, err := os.ReadDir(filepath.Join(tempDir, entry.Name()))
+		assert.NoError(t, err)
+		assert.Len(t, files, 6)
+		prefix := files[0].Name()[:2]
+		for _, f := range files {
+			assert.Equal(t, prefix, f.Name()[:2], "cluster %s mixes topics", entry.Name())
+		}
+	}
+}
+
+func TestRefactorFolder_TagStrategy(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "refactor-tag-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	files := map[string]string{
+		"alpha.md":   "---\ncategory: API Design\n---\n# Alpha\n",
+		"beta.md":    "---\ncategory: API Design\n---\n# Beta\n",
+		"gamma.md":   "# Gamma\n\nNotes about #ops and more #ops, see #api.\n",
+		"delta.md":   "# Delta\n\nRunbook #ops\n",
+		"guide_1.md": "# Guide 1\n",
+		"guide_2.md": "# Guide 2\n",
+		"guide_3.md": "# Guide 3\n",
+		"misc1.md":   "# Misc\n",
+		"misc2.md":   "# Misc\n",
+		"misc3.md":   "# Misc\n",
+		"misc4.md":   "# Misc\n",
+	}
+	for name, content := range files {
+		assert.NoError(t, os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0644))
+	}
+
+	err = server.RefactorFolderLogic(tempDir, tempDir, server.RefactorOptions{Strategy: server.StrategyTag})
+	assert.NoError(t, err)
+
+	assert.FileExists(t, filepath.Join(tempDir, "api-design", "alpha.md"))
+	assert.FileExists(t, filepath.Join(tempDir, "api-design", "beta.md"))
+	assert.FileExists(t, filepath.Join(tempDir, "ops", "gamma.md"))
+	assert.FileExists(t, filepath.Join(tempDir, "ops", "delta.md"))
+	assert.FileExists(t, filepath.Join(tempDir, "guide", "guide_1.md"))
+	assert.FileExists(t, filepath.Join(tempDir, "common", "misc1.md"))
+}
+
+func TestRefactorFolder_RecursiveLimit(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "refactor-recursive-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	write := func(path, content string) {
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+
+	for i := 0; i < 25; i++ {
+		write(filepath.Join(tempDir, fmt.Sprintf("guide_%02d.md", i)), "# Guide\n")
+	}
+	for i := 0; i < 3; i++ {
+		write(filepath.Join(tempDir, fmt.Sprintf("note%d.md", i)), "# Note\n")
+	}
+	for i := 0; i < 9; i++ {
+		write(filepath.Join(tempDir, fmt.Sprintf("area%d", i), "readme.md"), "# Area\n")
+	}
+	for i := 0; i < 14; i++ {
+		write(filepath.Join(tempDir, "area0", "deep", fmt.Sprintf("page%02d.md", i)), "# Page\n")
+	}
+	write(filepath.Join(tempDir, "note0.md"), "[deep](area0/deep/page03.md) [guide](guide_07.md)")
+
+	err = server.RefactorFolderLogic(tempDir, tempDir, server.RefactorOptions{})
+	assert.NoError(t, err)
+
+	total := 0
+	var note0 string
+	err = filepath.WalkDir(tempDir, func(path string, d os.DirEntry, err error) error {
+		assert.NoError(t, err)
+		if d.IsDir() {
+			entries, err := os.ReadDir(path)
+			assert.NoError(t, err)
+			assert.LessOrEqual(t, len(entries), 10, "folder %s exceeds the limit", path)
+		} else {
+			total++
+			if d.Name() == "note0.md" {
+				note0 = path
+			}
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 25+3+9+14, total)
+
+	content, err := os.ReadFile(note0)
+	assert.NoError(t, err)
+	for _, dest := range regexp.MustCompile(`\]\(([^)]+)\)`).FindAllStringSubmatch(string(content), -1) {
+		assert.FileExists(t, filepath.Join(filepath.Dir(note0), dest[1]))
+	}
+}
+
+func TestRefactorFolder_RewritesLinksFromOutsideTheFolder(t *testing.T) {
+	root := t.TempDir()
+	sub := filepath.Join(root, "sub")
+	assert.NoError(t, os.MkdirAll(sub, 0755))
+	for i := 0; i < 6; i++ {
+		assert.NoError(t, os.WriteFile(filepath.Join(sub, fmt.Sprintf("api_%d.md", i)), []byte("# API\n"), 0644))
+		assert.NoError(t, os.WriteFile(filepath.Join(sub, fmt.Sprintf("guide_%d.md", i)), []byte("# Guide\n"), 0644))
+	}
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "home.md"), []byte("[API](sub/api_2.md)\n"), 0644))
+
+	err := server.RefactorFolderLogic(root, sub, server.RefactorOptions{})
+	assert.NoError(t, err)
+
+	home, err := os.ReadFile(filepath.Join(root, "home.md"))
+	assert.NoError(t, err)
+	assert.Equal(t, "[API](sub/api/api_2.md)\n", string(home))
+	assert.FileExists(t, filepath.Join(sub, "api", "api_2.md"))
+}
+
+func TestRefactorFolder_BucketsFilesWithoutPrefix(t *testing.T) {
+	root := t.TempDir()
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, os.WriteFile(filepath.Join(root, fmt.Sprintf("_draft%d.md", i)), []byte("# Draft\n"), 0644))
+	}
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, os.WriteFile(filepath.Join(root, fmt.Sprintf("guide_%d.md", i)), []byte("# Guide\n"), 0644))
+	}
+
+	err := server.RefactorFolderLogic(root, root, server.RefactorOptions{})
+	assert.NoError(t, err)
+
+	entries, err := os.ReadDir(root)
+	assert.NoError(t, err)
+	assert.LessOrEqual(t, len(entries), 10)
+}
+
+func TestRefactorFolder_RejectsPathsOutsideDoc(t *testing.T) {
+	root := t.TempDir()
+	t.Chdir(root)
+	assert.NoError(t, os.MkdirAll("doc", 0755))
+	for i := 0; i < 11; i++ {
+		assert.NoError(t, os.MkdirAll(fmt.Sprintf("pkg%d", i), 0755))
+	}
+
+	for _, folder := range []string{".", "../", root, "doc/../internal"} {
+		result, err := server.RefactorFolder(context.Background(), nil, &mcp.CallToolParamsFor[server.RefactorFolderParams]{
+			Arguments: server.RefactorFolderParams{FolderPath: folder},
+		})
+		assert.NoError(t, err)
+		assert.True(t, result.IsError, folder)
+	}
+	assert.DirExists(t, filepath.Join(root, "doc"))
+	assert.DirExists(t, filepath.Join(root, "pkg0"))
+}
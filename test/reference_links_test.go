@@ -0,0 +1,86 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shardqa/doc-mcp/internal/server"
+	"github.com/stretchr/testify/require"
+)
+
+var referenceTree = map[string]string{
+	"home.md":         "# Home\n\nRead the [setup guide][setup], [it again][setup] and [Usage].\n\n![Logo][logo]\n\n[setup]: guides/setup.md \"Setup\"\n[usage]: <guides/setup.md#usage>\n[logo]: logo.png\n",
+	"api.md":          "# API\n\nSee [home](home.md).\n",
+	"logo.png":        "png",
+	"guides/setup.md": "# Setup\n\n## Usage\n\nBack [home].\n\n[home]: ../home.md\n",
+}
+
+func TestReferenceLinks_Validation(t *testing.T) {
+	initReq := `{"jsonrpc":"2.0","id":"init","method":"initialize","params":{"protocolVersion":"2024-11-05","capabilities":{},"clientInfo":{"name":"test","version":"1.0"}}}`
+	initNotif := `{"jsonrpc":"2.0","method":"notifications/initialized"}`
+	toolCall := `{"jsonrpc":"2.0","id":"1","method":"tools/call","params":{"name":"validate_markdown_file","arguments":{"content":"# Title\n\nSee [setup][s] and [the API][].\n\n[s]: setup.md\n[the api]: api.md\n[old]: old.md\n"}}}`
+
+	resp, _, _ := runMCP(initReq + "\n" + initNotif + "\n" + toolCall)
+	result := resp["result"].(map[string]interface{})
+	content := result["content"].([]interface{})
+	require.Len(t, content, 2)
+	warnings := content[1].(map[string]interface{})["text"].(string)
+	require.False(t, strings.Contains(warnings, "2 internal links"), warnings)
+	require.Contains(t, warnings, "Link definition [old]: old.md is not used")
+}
+
+func TestReferenceLinks_Graph(t *testing.T) {
+	root := writeTree(t, referenceTree)
+
+	backlinks, err := server.GetBacklinksLogic(root, "guides/setup.md")
+	require.NoError(t, err)
+	require.Len(t, backlinks, 3)
+	require.Equal(t, "setup guide", backlinks[0].Text)
+	require.Equal(t, 3, backlinks[0].Line)
+}
+
+func TestReferenceLinks_RewrittenOnMove(t *testing.T) {
+	root := writeTree(t, referenceTree)
+
+	_, _, err := server.MoveMarkdownFileLogic(root, "guides/setup.md", "setup.md")
+	require.NoError(t, err)
+
+	home, err := os.ReadFile(filepath.Join(root, "home.md"))
+	require.NoError(t, err)
+	require.Equal(t, "# Home\n\nRead the [setup guide][setup], [it again][setup] and [Usage].\n\n![Logo][logo]\n\n[setup]: setup.md \"Setup\"\n[usage]: <setup.md#usage>\n[logo]: logo.png\n", string(home))
+
+	setup, err := os.ReadFile(filepath.Join(root, "setup.md"))
+	require.NoError(t, err)
+	require.Equal(t, "# Setup\n\n## Usage\n\nBack [home].\n\n[home]: home.md\n", string(setup))
+
+	_, _, err = server.MoveMarkdownFileLogic(root, "home.md", "start/home.md")
+	require.NoError(t, err)
+	home, err = os.ReadFile(filepath.Join(root, "start", "home.md"))
+	require.NoError(t, err)
+	require.Contains(t, string(home), "[setup]: ../setup.md \"Setup\"\n[usage]: <../setup.md#usage>\n[logo]: logo.png\n")
+	require.FileExists(t, filepath.Join(root, "start", "logo.png"))
+}
+
+func TestReferenceLinks_StrippedOnDelete(t *testing.T) {
+	root := writeTree(t, referenceTree)
+
+	_, err := server.DeleteMarkdownFileLogic(root, "guides/setup.md", server.DeleteOptions{StripLinks: true})
+	require.NoError(t, err)
+
+	home, err := os.ReadFile(filepath.Join(root, "home.md"))
+	require.NoError(t, err)
+	require.Equal(t, "# Home\n\nRead the setup guide, it again and Usage.\n\n![Logo][logo]\n\n[logo]: logo.png\n", string(home))
+}
+
+func TestReferenceLinks_FollowRenamedHeading(t *testing.T) {
+	root := writeTree(t, referenceTree)
+
+	_, err := server.EditSectionLogic(root, "guides/setup.md", "Usage", "Using it", "")
+	require.NoError(t, err)
+
+	home, err := os.ReadFile(filepath.Join(root, "home.md"))
+	require.NoError(t, err)
+	require.Contains(t, string(home), "[usage]: <guides/setup.md#using-it>\n")
+}
@@ -0,0 +1,90 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/shardqa/doc-mcp/internal/server"
+	"github.com/stretchr/testify/require"
+)
+
+var searchTree = map[string]string{
+	"guides/getting-started.md": "---\ntags: [onboarding]\n---\n# Getting Started\n\nInstall the CLI and run the server.\n\n## Configuration\n\nSet the token in the config file.\n",
+	"api/auth.md":               "# Authentication\n\nEvery request needs a bearer token. #api\n\nTokens expire after one hour.\n",
+	"ops/runbook.md":            "# Runbook\n\nRestart the server when the token cache is stale.\n",
+}
+
+func resultPaths(results []server.SearchResult) []string {
+	paths := []string{}
+	for _, r := range results {
+		paths = append(paths, r.Path)
+	}
+	return paths
+}
+
+func TestSearchIndex_RanksAndSnippets(t *testing.T) {
+	idx := server.NewSearchIndex(writeTree(t, searchTree))
+	require.NoError(t, idx.Build())
+
+	results := idx.Search("bearer token", 10)
+	require.Len(t, results, 3)
+	require.Equal(t, "api/auth.md", results[0].Path)
+	require.Equal(t, "Authentication", results[0].Title)
+	require.Equal(t, []server.Snippet{
+		{Line: 3, Text: "Every request needs a bearer token. #api"},
+	}, results[0].Snippets)
+
+	results = idx.Search("configuration", 10)
+	require.Len(t, results, 1)
+	require.Equal(t, 8, results[0].Snippets[0].Line)
+}
+
+func TestSearchIndex_PhrasesAndFilters(t *testing.T) {
+	idx := server.NewSearchIndex(writeTree(t, searchTree))
+	require.NoError(t, idx.Build())
+
+	require.Equal(t, []string{"ops/runbook.md"}, resultPaths(idx.Search(`"restart the server"`, 10)))
+	require.Empty(t, idx.Search(`"server restart"`, 10))
+	require.Equal(t, []string{"guides/getting-started.md"}, resultPaths(idx.Search(`token title:"getting started"`, 10)))
+	require.Equal(t, []string{"guides/getting-started.md"}, resultPaths(idx.Search("heading:configuration", 10)))
+	require.Equal(t, []string{"api/auth.md"}, resultPaths(idx.Search("tag:api", 10)))
+	require.Equal(t, []string{"guides/getting-started.md"}, resultPaths(idx.Search("tag:onboarding server", 10)))
+	require.Equal(t, []string{"ops/runbook.md"}, resultPaths(idx.Search("token path:ops", 10)))
+}
+
+func TestSearchIndex_Update(t *testing.T) {
+	root := writeTree(t, searchTree)
+	idx := server.NewSearchIndex(root)
+	require.NoError(t, idx.Build())
+
+	path := filepath.Join(root, "ops", "deploy.md")
+	require.NoError(t, os.WriteFile(path, []byte("# Deploy\n\nRoll out with kubernetes.\n"), 0644))
+	idx.Update(path)
+	require.Equal(t, []string{"ops/deploy.md"}, resultPaths(idx.Search("kubernetes", 10)))
+
+	require.NoError(t, os.Remove(path))
+	idx.Update(path)
+	require.Empty(t, idx.Search("kubernetes", 10))
+}
+
+func TestSearchDocsLogic_CRLFFrontmatterAndEmptyQuery(t *testing.T) {
+	root := t.TempDir()
+	doc := "---\r\ntags: [ops]\r\n---\r\n# Runbook\r\n\r\nRotate the signing keys.\r\n"
+	require.NoError(t, os.WriteFile(filepath.Join(root, "runbook.md"), []byte(doc), 0644))
+
+	results, err := server.SearchDocsLogic(root, "signing", 10)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	require.Equal(t, 6, results[0].Snippets[0].Line)
+	require.Equal(t, "Rotate the signing keys.", results[0].Snippets[0].Text)
+
+	for _, query := range []string{"  ", "???", "--"} {
+		_, err = server.SearchDocsLogic(root, query, 10)
+		require.Error(t, err, query)
+	}
+
+	results, err = server.SearchDocsLogic(root, "tag:ops", 10)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+}
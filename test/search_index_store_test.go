@@ -0,0 +1,72 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shardqa/doc-mcp/internal/server"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchIndex_LoadReusesUnchangedFiles(t *testing.T) {
+	root := writeTree(t, searchTree)
+
+	first := server.NewSearchIndex(root)
+	require.NoError(t, first.Load())
+	stats := first.Stats()
+	require.Equal(t, 3, stats.Documents)
+	require.Equal(t, 3, stats.Reindexed)
+	require.FileExists(t, filepath.Join(root, ".doc-mcp", "index", "search.json"))
+
+	runbook := filepath.Join(root, "ops", "runbook.md")
+	require.NoError(t, os.WriteFile(runbook, []byte("# Runbook\n\nRotate the signing keys.\n"), 0644))
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(runbook, later, later))
+	require.NoError(t, os.Remove(filepath.Join(root, "api", "auth.md")))
+
+	touched := filepath.Join(root, "guides", "getting-started.md")
+	require.NoError(t, os.Chtimes(touched, later, later))
+
+	second := server.NewSearchIndex(root)
+	require.NoError(t, second.Load())
+	stats = second.Stats()
+	require.Equal(t, 2, stats.Documents)
+	require.Equal(t, 1, stats.Reused)
+	require.Equal(t, 1, stats.Reindexed)
+	require.Equal(t, 1, stats.Removed)
+
+	require.Equal(t, []string{"ops/runbook.md"}, resultPaths(second.Search("signing keys", 10)))
+	require.Empty(t, second.Search("bearer", 10))
+
+	require.NoError(t, second.Build())
+	stats = second.Stats()
+	require.Equal(t, 0, stats.Reused)
+	require.Equal(t, 2, stats.Reindexed)
+}
+
+func TestSearchIndex_UpdateFolderAndDeferredSave(t *testing.T) {
+	root := writeTree(t, searchTree)
+	idx := server.NewSearchIndex(root)
+	require.NoError(t, idx.Load())
+	saved, err := os.ReadFile(filepath.Join(root, ".doc-mcp", "index", "search.json"))
+	require.NoError(t, err)
+
+	require.NoError(t, os.RemoveAll(filepath.Join(root, "api")))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "ops", "keys.md"), []byte("# Keys\n\nRotate the signing keys.\n"), 0644))
+	require.NoError(t, idx.Update(filepath.Join(root, "api"), filepath.Join(root, "ops")))
+
+	require.Equal(t, 3, idx.Stats().Documents)
+	require.Empty(t, idx.Search("bearer", 10))
+	require.Equal(t, []string{"ops/keys.md"}, resultPaths(idx.Search("signing keys", 10)))
+
+	unsaved, err := os.ReadFile(filepath.Join(root, ".doc-mcp", "index", "search.json"))
+	require.NoError(t, err)
+	require.Equal(t, string(saved), string(unsaved))
+
+	require.NoError(t, idx.Flush())
+	reloaded := server.NewSearchIndex(root)
+	require.NoError(t, reloaded.Load())
+	require.Equal(t, 3, reloaded.Stats().Reused)
+}
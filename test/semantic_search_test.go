@@ -0,0 +1,93 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shardqa/doc-mcp/internal/server"
+	"github.com/stretchr/testify/require"
+)
+
+// countingEmbedder wraps the hash embedder and counts the texts it embeds.
+type countingEmbedder struct {
+	server.HashEmbedder
+	texts int
+}
+
+func (e *countingEmbedder) Embed(texts []string) ([][]float32, error) {
+	e.texts += len(texts)
+	return e.HashEmbedder.Embed(texts)
+}
+
+var semanticTree = map[string]string{
+	"security.md": "# Security\n\nIntro to the security model.\n\n## Credentials\n\nRotate the API credentials every ninety days and revoke old keys.\n\n## Auditing\n\nAudit logs are kept for a year.\n",
+	"deploy.md":   "# Deployment\n\nShip builds to production with the release pipeline.\n",
+}
+
+func TestSemanticIndex_FindsParaphrasedSection(t *testing.T) {
+	root := writeTree(t, semanticTree)
+	idx := server.NewSemanticIndex(root, server.HashEmbedder{})
+
+	results, err := idx.Search("how often should credentials be rotated", 3)
+	require.NoError(t, err)
+	require.NotEmpty(t, results)
+	require.Equal(t, "security.md", results[0].Path)
+	require.Equal(t, "Security > Credentials", results[0].Heading)
+	require.Equal(t, 5, results[0].Line)
+	require.Equal(t, 7, results[0].EndLine)
+	require.Contains(t, results[0].Text, "ninety days")
+	require.Greater(t, results[0].Score, 0.0)
+}
+
+func TestSemanticIndex_StoresVectorsAndReembedsChangedFiles(t *testing.T) {
+	root := writeTree(t, semanticTree)
+
+	embedder := &countingEmbedder{}
+	count, err := server.NewSemanticIndex(root, embedder).Refresh()
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+	require.Equal(t, 4, embedder.texts)
+	require.FileExists(t, filepath.Join(root, ".doc-mcp", "vectors", "hash-256.json"))
+
+	deploy := filepath.Join(root, "deploy.md")
+	require.NoError(t, os.WriteFile(deploy, []byte("# Deployment\n\nRoll back with the previous image tag.\n"), 0644))
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(deploy, later, later))
+
+	embedder = &countingEmbedder{}
+	idx := server.NewSemanticIndex(root, embedder)
+	count, err = idx.Refresh()
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+	require.Equal(t, 1, embedder.texts)
+
+	results, err := idx.Search("rolling back a release", 1)
+	require.NoError(t, err)
+	require.Equal(t, "deploy.md", results[0].Path)
+}
+
+func TestHTTPEmbedder(t *testing.T) {
+	var request struct {
+		Model string   `json:"model"`
+		Input []string `json:"input"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		w.Write([]byte(`{"data":[{"index":1,"embedding":[0,1]},{"index":0,"embedding":[1,0]}]}`))
+	}))
+	defer srv.Close()
+
+	embedder := &server.HTTPEmbedder{URL: srv.URL, Model: "Text Embed", APIKey: "secret"}
+	vectors, err := embedder.Embed([]string{"a", "b"})
+	require.NoError(t, err)
+	require.Equal(t, "Text Embed", request.Model)
+	require.Equal(t, []string{"a", "b"}, request.Input)
+	require.Equal(t, [][]float32{{1, 0}, {0, 1}}, vectors)
+	require.Equal(t, "http-text-embed", embedder.Name())
+}
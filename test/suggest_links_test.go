@@ -0,0 +1,57 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/shardqa/doc-mcp/internal/server"
+	"github.com/stretchr/testify/require"
+)
+
+var suggestTree = map[string]string{
+	"guides/deploy.md":   "---\ntags: [ops]\n---\n# Deploying\n\nRoll out the release with the deploy pipeline and watch the rollout dashboard.\n",
+	"guides/rollback.md": "# Rolling Back\n\nRoll back a bad release from the deploy pipeline. See [deploying](deploy.md).\n",
+	"ops/alerts.md":      "---\ntags: [ops]\n---\n# Alerts\n\nPager alerts fire when error rates rise after a release.\n",
+	"api/users.md":       "# Users API\n\nCreate and list users.\n",
+}
+
+func TestSuggestLinksLogic_ExistingFile(t *testing.T) {
+	root := writeTree(t, suggestTree)
+
+	suggestions, err := server.SuggestLinksLogic(root, "guides/rollback.md", "", 0)
+	require.NoError(t, err)
+	require.Len(t, suggestions, 1)
+	require.Equal(t, "ops/alerts.md", suggestions[0].Path, "deploy.md is already linked")
+	require.Equal(t, "[Alerts](../ops/alerts.md)", suggestions[0].Markdown)
+}
+
+func TestSuggestLinksLogic_Content(t *testing.T) {
+	root := writeTree(t, suggestTree)
+
+	content := "---\ntags: [ops]\n---\n# Release checklist\n\nBefore the release, check the deploy pipeline and the rollout dashboard.\n"
+	suggestions, err := server.SuggestLinksLogic(root, "ops/checklist.md", content, 2)
+	require.NoError(t, err)
+	require.Len(t, suggestions, 2)
+	require.Equal(t, "guides/deploy.md", suggestions[0].Path)
+	require.Equal(t, "[Deploying](../guides/deploy.md)", suggestions[0].Markdown)
+	require.Equal(t, []string{"ops"}, suggestions[0].SharedTags)
+	require.Contains(t, suggestions[0].SharedTerms, "pipeline")
+
+	suggestions, err = server.SuggestLinksLogic(root, "", content, 1)
+	require.NoError(t, err)
+	require.Equal(t, "[Deploying](guides/deploy.md)", suggestions[0].Markdown)
+
+	_, err = server.SuggestLinksLogic(root, "", "", 0)
+	require.Error(t, err)
+}
+
+func TestSuggestLinksLogic_EscapesDestination(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"release notes/deploy (v2).md": "# Deploying\n\nRoll out the release with the deploy pipeline and watch the rollout dashboard.\n",
+	})
+
+	suggestions, err := server.SuggestLinksLogic(root, "", "# Checklist\n\nCheck the deploy pipeline and the rollout dashboard before the release.\n", 1)
+	require.NoError(t, err)
+	require.Len(t, suggestions, 1)
+	require.Equal(t, "release notes/deploy (v2).md", suggestions[0].Path)
+	require.Equal(t, "[Deploying](release%20notes/deploy%20%28v2%29.md)", suggestions[0].Markdown)
+}
@@ -0,0 +1,121 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/shardqa/doc-mcp/internal/server"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+const adrTemplate = `{{/* An architecture decision record. */}}
+{{/* Status: proposed, accepted or superseded. */}}
+# ADR: {{.Title}}
+
+Status: {{or .Status "proposed"}}
+Owner: {{.Owner}}
+
+{{range .Related}}- {{.Markdown}}
+{{end}}`
+
+func TestListTemplatesLogic(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "templates"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "templates", "adr.md"), []byte(adrTemplate), 0644))
+
+	templates, err := server.ListTemplatesLogic(root)
+	require.NoError(t, err)
+	require.Len(t, templates, 2)
+
+	adr := templates[0]
+	require.Equal(t, "adr", adr.Name)
+	require.Equal(t, "templates/adr.md", adr.Path)
+	require.Equal(t, "An architecture decision record.", adr.Description)
+	require.Equal(t, []server.TemplateVariable{
+		{Name: "Title", Description: "the document title; defaults to the file name", Auto: true},
+		{Name: "Status", Description: "proposed, accepted or superseded."},
+		{Name: "Owner", Required: true},
+		{Name: "Related", Description: "suggested links to related documents, each with .Path, .Title and .Markdown", Auto: true},
+	}, adr.Variables)
+
+	standard := templates[1]
+	require.Equal(t, "standard", standard.Name)
+	require.Empty(t, standard.Path)
+	names := []string{}
+	for _, v := range standard.Variables {
+		require.False(t, v.Required, v.Name)
+		names = append(names, v.Name)
+	}
+	require.Equal(t, []string{"Title", "Author", "Date", "Tags", "Summary", "Details", "Related"}, names)
+}
+
+func TestCreateFromTemplateLogic_Standard(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "caching.md"), []byte("# Caching\n\nHow the cache layer stores rendered pages.\n"), 0644))
+
+	content, err := server.CreateFromTemplateLogic(root, "", "guides/page-cache.md", map[string]string{
+		"Author":  "Ada",
+		"Summary": "How rendered pages are cached by the cache layer.",
+	})
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(content, "---\ntitle: \"Page cache\"\nauthor: \"Ada\"\ndate: "+time.Now().Format("2006-01-02")+"\n---\n\n# Page cache\n"), content)
+	require.Contains(t, content, "## Overview\n\nHow rendered pages are cached by the cache layer.\n")
+	require.Contains(t, content, "## Details\n\nTODO: the details.\n")
+	require.Contains(t, content, "## Related\n\n- [Caching](../caching.md)\n")
+
+	written, err := os.ReadFile(filepath.Join(root, "guides", "page-cache.md"))
+	require.NoError(t, err)
+	require.Equal(t, content, string(written))
+
+	_, err = server.CreateFromTemplateLogic(root, "", "guides/page-cache.md", nil)
+	require.Error(t, err)
+}
+
+func TestCreateFromTemplateLogic_Variables(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "templates"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "templates", "adr.md"), []byte(adrTemplate), 0644))
+
+	_, err := server.CreateFromTemplateLogic(root, "adr", "adr/0001-use-go.md", nil)
+	require.ErrorContains(t, err, "needs Owner")
+	_, err = server.CreateFromTemplateLogic(root, "adr", "adr/0001-use-go.md", map[string]string{"Owner": "Ada", "Color": "red"})
+	require.ErrorContains(t, err, "no variable Color")
+	_, err = server.CreateFromTemplateLogic(root, "missing", "adr/0001-use-go.md", nil)
+	require.Error(t, err)
+	_, err = server.CreateFromTemplateLogic(root, "adr", "templates/new.md", map[string]string{"Owner": "Ada"})
+	require.Error(t, err)
+
+	content, err := server.CreateFromTemplateLogic(root, "adr", "adr/0001-use-go.md", map[string]string{"Owner": "Ada", "Title": "Use Go"})
+	require.NoError(t, err)
+	require.Equal(t, "\n\n# ADR: Use Go\n\nStatus: proposed\nOwner: Ada\n\n", content)
+
+	// Templates are not documents.
+	_, err = server.GetOutgoingLinksLogic(root, "templates/adr.md")
+	require.Error(t, err)
+	results, err := server.SearchDocsLogic(root, "architecture decision", 10)
+	require.NoError(t, err)
+	require.Empty(t, results)
+}
+
+func TestCreateFromTemplateLogic_UnicodeTitle(t *testing.T) {
+	root := t.TempDir()
+
+	content, err := server.CreateFromTemplateLogic(root, "", "élan-vital.md", map[string]string{"Author": "Zoë \"Z\"\x01"})
+	require.NoError(t, err)
+	require.True(t, utf8.ValidString(content))
+	require.Contains(t, content, "# Élan vital\n")
+
+	var meta struct {
+		Title  string `yaml:"title"`
+		Author string `yaml:"author"`
+	}
+	frontmatter := strings.SplitN(content, "---\n", 3)[1]
+	require.NoError(t, yaml.Unmarshal([]byte(frontmatter), &meta))
+	require.Equal(t, "Élan vital", meta.Title)
+	require.Equal(t, "Zoë \"Z\"\x01", meta.Author)
+}
@@ -0,0 +1,103 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shardqa/doc-mcp/internal/server"
+	"github.com/stretchr/testify/require"
+)
+
+const tocDoc = "# Guide\n\nIntro.\n\n## Install\n\n### On Linux\n\n#### Details\n\n## Usage [beta]\n\n## Install\n"
+
+func TestUpdateTOCLogic_InsertsAndRefreshes(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "guides", "guide.md")
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, os.WriteFile(path, []byte(tocDoc), 0644))
+
+	updated, err := server.UpdateTOCLogic(root, "guides/guide.md", 0)
+	require.NoError(t, err)
+	require.Equal(t, []string{"guides/guide.md"}, updated)
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "# Guide\n\n"+
+		"<!-- doc-mcp:toc:start depth=3 -->\n"+
+		"- [Install](#install)\n"+
+		"  - [On Linux](#on-linux)\n"+
+		"- [Usage \\[beta\\]](#usage-beta)\n"+
+		"- [Install](#install-1)\n"+
+		"<!-- doc-mcp:toc:end -->\n"+
+		"\nIntro.\n\n## Install\n\n### On Linux\n\n#### Details\n\n## Usage [beta]\n\n## Install\n", string(content))
+
+	updated, err = server.UpdateTOCLogic(root, "", 0)
+	require.NoError(t, err)
+	require.Empty(t, updated)
+
+	edited := strings.Replace(string(content), "## Usage [beta]", "## Usage", 1)
+	require.NoError(t, os.WriteFile(path, []byte(edited), 0644))
+	updated, err = server.UpdateTOCLogic(root, "guides", 2)
+	require.NoError(t, err)
+	require.Equal(t, []string{"guides/guide.md"}, updated)
+
+	content, err = os.ReadFile(path)
+	require.NoError(t, err)
+	require.Contains(t, string(content), "<!-- doc-mcp:toc:start depth=2 -->\n- [Install](#install)\n- [Usage](#usage)\n- [Install](#install-1)\n<!-- doc-mcp:toc:end -->\n")
+}
+
+func TestUpdateTOCLogic_FoldersOnlyRefresh(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "guide.md"), []byte(tocDoc), 0644))
+
+	updated, err := server.UpdateTOCLogic(root, "", 0)
+	require.NoError(t, err)
+	require.Empty(t, updated)
+
+	_, err = server.UpdateTOCLogic(root, "missing.md", 0)
+	require.Error(t, err)
+}
+
+func TestValidateMarkdownFile_StaleTOC(t *testing.T) {
+	initReq := `{"jsonrpc":"2.0","id":"init","method":"initialize","params":{"protocolVersion":"2024-11-05","capabilities":{},"clientInfo":{"name":"test","version":"1.0"}}}`
+	initNotif := `{"jsonrpc":"2.0","method":"notifications/initialized"}`
+	toolCall := `{"jsonrpc":"2.0","id":"1","method":"tools/call","params":{"name":"validate_markdown_file","arguments":{"content":"# Title\n\n<!-- doc-mcp:toc:start depth=3 -->\n- [Old](#old)\n<!-- doc-mcp:toc:end -->\n\n## New\n\nSee [a](a.md) and [b](b.md).\n"}}}`
+
+	resp, _, _ := runMCP(initReq + "\n" + initNotif + "\n" + toolCall)
+	result := resp["result"].(map[string]interface{})
+	content := result["content"].([]interface{})
+	require.Len(t, content, 2)
+	warnings := content[1].(map[string]interface{})["text"].(string)
+	require.Contains(t, warnings, "Table of contents is out of date")
+	require.NotContains(t, warnings, "#old")
+}
+
+func TestUpdateTOCLogic_IgnoresMarkersInCode(t *testing.T) {
+	root := t.TempDir()
+	example := "```markdown\n<!-- doc-mcp:toc:start -->\n<!-- doc-mcp:toc:end -->\n```\n"
+	doc := "# Guide\n\nUse `<!-- doc-mcp:toc:start -->` and `<!-- doc-mcp:toc:end -->`.\n\n" + example + "\n## Install\n\n## Usage\n"
+	require.NoError(t, os.WriteFile(filepath.Join(root, "guide.md"), []byte(doc), 0644))
+
+	_, err := server.UpdateTOCLogic(root, "guide.md", 0)
+	require.NoError(t, err)
+
+	content, err := os.ReadFile(filepath.Join(root, "guide.md"))
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(string(content), "# Guide\n\n<!-- doc-mcp:toc:start depth=3 -->\n- [Install](#install)\n- [Usage](#usage)\n<!-- doc-mcp:toc:end -->\n"))
+	require.Contains(t, string(content), example)
+}
+
+func TestUpdateTOCLogic_ClampsDepth(t *testing.T) {
+	root := t.TempDir()
+	doc := "# Guide\n\n<!-- doc-mcp:toc:start depth=0 -->\n<!-- doc-mcp:toc:end -->\n\n## Install\n\n### On Linux\n"
+	require.NoError(t, os.WriteFile(filepath.Join(root, "guide.md"), []byte(doc), 0644))
+
+	_, err := server.UpdateTOCLogic(root, "guide.md", -2)
+	require.NoError(t, err)
+
+	content, err := os.ReadFile(filepath.Join(root, "guide.md"))
+	require.NoError(t, err)
+	require.Contains(t, string(content), "<!-- doc-mcp:toc:start depth=3 -->\n- [Install](#install)\n  - [On Linux](#on-linux)\n<!-- doc-mcp:toc:end -->\n")
+}
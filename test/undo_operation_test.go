@@ -0,0 +1,144 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/shardqa/doc-mcp/internal/server"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUndoLastOperation_RevertsRefactor(t *testing.T) {
+	root := t.TempDir()
+	writeNumberedFiles(t, root, 12)
+	require.NoError(t, os.WriteFile(filepath.Join(root, "alpha_0.md"), []byte("[b](beta_1.md)\n"), 0644))
+
+	tracker, err := server.BeginOperation(root, "refactor_folder", nil, root)
+	require.NoError(t, err)
+	require.NoError(t, server.RefactorFolderLogic(root, root, server.RefactorOptions{}))
+	op, err := tracker.Finish()
+	require.NoError(t, err)
+	require.NotNil(t, op)
+	require.Equal(t, 1, op.ID)
+	require.Len(t, op.Files, 24)
+
+	ops, err := server.ListOperationsLogic(root)
+	require.NoError(t, err)
+	require.Len(t, ops, 1)
+
+	undone, err := server.UndoLastOperationLogic(root, false)
+	require.NoError(t, err)
+	require.Equal(t, "refactor_folder", undone.Tool)
+
+	content, err := os.ReadFile(filepath.Join(root, "alpha_0.md"))
+	require.NoError(t, err)
+	require.Equal(t, "[b](beta_1.md)\n", string(content))
+	require.NoDirExists(t, filepath.Join(root, "alpha"))
+	require.NoDirExists(t, filepath.Join(root, "beta"))
+
+	ops, err = server.ListOperationsLogic(root)
+	require.NoError(t, err)
+	require.Empty(t, ops)
+}
+
+func TestUndoLastOperation_RefusesWhenChangedSince(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "note.md")
+
+	tracker, err := server.BeginOperation(root, "create_markdown_file", nil, path)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, []byte("# Note\n"), 0644))
+	_, err = tracker.Finish()
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(path, []byte("# Edited by hand\n"), 0644))
+
+	_, err = server.UndoLastOperationLogic(root, false)
+	require.Error(t, err)
+	require.FileExists(t, path)
+
+	_, err = server.UndoLastOperationLogic(root, true)
+	require.NoError(t, err)
+	require.NoFileExists(t, path)
+}
+
+func TestUndoLastOperation_NothingToUndo(t *testing.T) {
+	_, err := server.UndoLastOperationLogic(t.TempDir(), false)
+	require.Error(t, err)
+}
+
+func TestBeginOperation_DeduplicatesScopes(t *testing.T) {
+	root := t.TempDir()
+	t.Chdir(root)
+	require.NoError(t, os.MkdirAll("guides", 0755))
+
+	tracker, err := server.BeginOperation(root, "create_markdown_file", nil, filepath.Join(root, "guides", "setup.md"), "guides", "guides/")
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join("guides", "setup.md"), []byte("# Setup\n"), 0644))
+	op, err := tracker.Finish()
+	require.NoError(t, err)
+	require.Len(t, op.Files, 1)
+	require.Equal(t, filepath.Join(root, "guides", "setup.md"), op.Files[0].Path)
+}
+
+func TestFinish_PrunesOldOperations(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "note.md")
+
+	for i := 0; i < 105; i++ {
+		tracker, err := server.BeginOperation(root, "edit_markdown_file", nil, path)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(path, []byte(fmt.Sprintf("# Note %d\n", i)), 0644))
+		_, err = tracker.Finish()
+		require.NoError(t, err)
+	}
+
+	ops, err := server.ListOperationsLogic(root)
+	require.NoError(t, err)
+	require.Len(t, ops, 100)
+	require.Equal(t, 6, ops[0].ID)
+	require.Equal(t, 105, ops[len(ops)-1].ID)
+
+	objects, err := os.ReadDir(filepath.Join(root, ".doc-mcp", "journal", "objects"))
+	require.NoError(t, err)
+	require.Len(t, objects, 101)
+}
+
+func TestUndoLastOperation_KeepsRelativeRoot(t *testing.T) {
+	t.Chdir(t.TempDir())
+	path := filepath.Join("doc", "guides", "setup.md")
+
+	tracker, err := server.BeginOperation("doc", "create_markdown_file", nil, path)
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, os.WriteFile(path, []byte("# Setup\n"), 0644))
+	_, err = tracker.Finish()
+	require.NoError(t, err)
+
+	_, err = server.UndoLastOperationLogic("doc", false)
+	require.NoError(t, err)
+	require.NoFileExists(t, path)
+	require.NoDirExists(t, filepath.Dir(path))
+	require.DirExists(t, "doc")
+}
+
+func TestUndoLastOperation_DropsTrashedCopy(t *testing.T) {
+	t.Chdir(t.TempDir())
+	require.NoError(t, os.MkdirAll("doc", 0755))
+	require.NoError(t, os.WriteFile(filepath.Join("doc", "old.md"), []byte("# Old\n"), 0644))
+
+	result, err := server.DeleteMarkdownFile(context.Background(), nil, &mcp.CallToolParamsFor[server.DeleteMarkdownFileParams]{
+		Arguments: server.DeleteMarkdownFileParams{Path: "old.md"},
+	})
+	require.NoError(t, err)
+	require.False(t, result.IsError)
+
+	_, err = server.UndoLastOperationLogic("doc", false)
+	require.NoError(t, err)
+	require.FileExists(t, filepath.Join("doc", "old.md"))
+	require.NoDirExists(t, filepath.Join("doc", ".doc-mcp", "trash"))
+}
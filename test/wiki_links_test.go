@@ -0,0 +1,97 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/shardqa/doc-mcp/internal/server"
+	"github.com/stretchr/testify/require"
+)
+
+var wikiTree = map[string]string{
+	"guides/setup.md": "# Setup Guide\n\n## Install\n\nRun the installer.\n",
+	"home.md":         "# Home\n\nStart with [[setup]], the [[Setup Guide]] or [[guides/setup#Install|installing]].\nAlso [reference](api.md) and [[Nowhere]].\n",
+	"api.md":          "# API\n\nSee [[home]].\n",
+}
+
+func TestWikiLinks_Validation(t *testing.T) {
+	initReq := `{"jsonrpc":"2.0","id":"init","method":"initialize","params":{"protocolVersion":"2024-11-05","capabilities":{},"clientInfo":{"name":"test","version":"1.0"}}}`
+	initNotif := `{"jsonrpc":"2.0","method":"notifications/initialized"}`
+	toolCall := `{"jsonrpc":"2.0","id":"1","method":"tools/call","params":{"name":"validate_markdown_file","arguments":{"content":"# Title\n\nSee [[Setup]] and [[guides/api|the API]].\n"}}}`
+
+	resp, _, _ := runMCP(initReq + "\n" + initNotif + "\n" + toolCall)
+	result := resp["result"].(map[string]interface{})
+	require.Len(t, result["content"].([]interface{}), 1)
+}
+
+func TestWikiLinks_Graph(t *testing.T) {
+	root := writeTree(t, wikiTree)
+
+	backlinks, err := server.GetBacklinksLogic(root, "guides/setup.md")
+	require.NoError(t, err)
+	require.Len(t, backlinks, 3)
+	require.Equal(t, "installing", backlinks[2].Text)
+
+	outgoing, err := server.GetOutgoingLinksLogic(root, "home.md")
+	require.NoError(t, err)
+	require.Len(t, outgoing, 5)
+	require.Equal(t, "Nowhere", outgoing[4].Path)
+	require.True(t, outgoing[4].Missing)
+}
+
+func TestWikiLinks_RewrittenOnMove(t *testing.T) {
+	root := writeTree(t, wikiTree)
+
+	_, _, err := server.MoveMarkdownFileLogic(root, "guides/setup.md", "install.md")
+	require.NoError(t, err)
+
+	home, err := os.ReadFile(filepath.Join(root, "home.md"))
+	require.NoError(t, err)
+	require.Equal(t, "# Home\n\nStart with [[install]], the [[Setup Guide]] or [[install#Install|installing]].\nAlso [reference](api.md) and [[Nowhere]].\n", string(home))
+}
+
+func TestWikiLinks_StrippedOnDelete(t *testing.T) {
+	root := writeTree(t, wikiTree)
+
+	_, err := server.DeleteMarkdownFileLogic(root, "guides/setup.md", server.DeleteOptions{})
+	require.Error(t, err)
+
+	_, err = server.DeleteMarkdownFileLogic(root, "guides/setup.md", server.DeleteOptions{StripLinks: true})
+	require.NoError(t, err)
+	home, err := os.ReadFile(filepath.Join(root, "home.md"))
+	require.NoError(t, err)
+	require.Equal(t, "# Home\n\nStart with setup, the Setup Guide or installing.\nAlso [reference](api.md) and [[Nowhere]].\n", string(home))
+}
+
+func TestConvertWikiLinksLogic(t *testing.T) {
+	root := writeTree(t, wikiTree)
+
+	converted, unresolved, err := server.ConvertWikiLinksLogic(root, "")
+	require.NoError(t, err)
+	require.Len(t, converted, 2)
+	require.Len(t, unresolved, 1)
+
+	home, err := os.ReadFile(filepath.Join(root, "home.md"))
+	require.NoError(t, err)
+	require.Equal(t, "# Home\n\nStart with [setup](guides/setup.md), the [Setup Guide](guides/setup.md) or [installing](guides/setup.md#install).\nAlso [reference](api.md) and [[Nowhere]].\n", string(home))
+
+	api, err := os.ReadFile(filepath.Join(root, "api.md"))
+	require.NoError(t, err)
+	require.Equal(t, "# API\n\nSee [home](home.md).\n", string(api))
+}
+
+func TestConvertWikiLinksLogic_SpacesAndSamePage(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"My Page.md": "# My Page\n",
+		"home.md":    "# Home\n\nSee [[My Page]] and [[#Next Steps]].\n\n## Next Steps\n",
+	})
+
+	_, unresolved, err := server.ConvertWikiLinksLogic(root, "home.md")
+	require.NoError(t, err)
+	require.Empty(t, unresolved)
+
+	home, err := os.ReadFile(filepath.Join(root, "home.md"))
+	require.NoError(t, err)
+	require.Equal(t, "# Home\n\nSee [My Page](My%20Page.md) and [Next Steps](#next-steps).\n\n## Next Steps\n", string(home))
+}